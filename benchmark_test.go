@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/yokomotod/yuccadb"
 	"github.com/yokomotod/yuccadb/internals/testdata"
+	yuccaTable "github.com/yokomotod/yuccadb/table"
 )
 
 // const size = 10_000_000
@@ -96,3 +98,33 @@ func BenchmarkDBParallel(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkDBSSTable(b *testing.B) {
+	testFile := testdata.TestCsvPath("./testdata", tableSize)
+	sstFile := filepath.Join(b.TempDir(), "test.sst")
+
+	db := yuccadb.NewYuccaDB()
+
+	if err := yuccaTable.ConvertToSSTable(testFile, sstFile, db.Logger, yuccaTable.BuildOptions{}); err != nil {
+		b.Fatal(err)
+	}
+
+	if err := db.PutTable("test", sstFile, false); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+
+	for keySeed := 0; keySeed < b.N; keySeed++ {
+		key := fmt.Sprintf("%010d", keySeed%tableSize)
+
+		res, err := db.GetValue("test", key)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if res.Values == nil {
+			b.Fatalf("key %q does not exist", key)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+// Package bgzf reads and writes block-compressed gzip files,
+// which are concatenated gzip members of up to 64 KiB of uncompressed data each.
+//
+// Any gzip file is readable, and random access is as fine as its members are small.
+// Writer writes BGZF (as used by samtools) with the "BC" extra field and the empty end-of-file block,
+// so the files can also be read by gzip and other BGZF tools.
+package bgzf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+const (
+	// BlockSize is the max uncompressed size of blocks written by Writer.
+	// It leaves room for the headers and for incompressible data, as in htslib,
+	// so that the compressed size of a block always fits in the 16-bit BSIZE of the "BC" extra field.
+	BlockSize = 0xff00
+
+	// the "BC" extra field holds the block size - 1 as uint16 at this offset
+	bsizeOffset = 16
+	// maxBlockSize is the largest compressed block which BSIZE can hold.
+	maxBlockSize = 1 << 16
+)
+
+var errBlockTooLarge = errors.New("block is too large for BGZF")
+
+// eofBlock is the empty block written at the end of BGZF files.
+var eofBlock = []byte{ //nolint:gochecknoglobals
+	0x1f, 0x8b, 0x08, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0x06, 0x00, 0x42, 0x43,
+	0x02, 0x00, 0x1b, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+// Block locates a gzip member in the compressed file and its data in the uncompressed stream.
+type Block struct {
+	CompressedOffset   int64
+	UncompressedOffset int64
+}
+
+// Writer compresses each BlockSize of data into a separate gzip member.
+type Writer struct {
+	w     io.Writer
+	buf   []byte
+	block bytes.Buffer
+	gz    *gzip.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:   w,
+		buf: make([]byte, 0, BlockSize),
+	}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	n := 0
+
+	for len(p) > 0 {
+		size := min(len(p), BlockSize-len(w.buf))
+		w.buf = append(w.buf, p[:size]...)
+		p = p[size:]
+		n += size
+
+		if len(w.buf) == BlockSize {
+			if err := w.flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// flush writes buffered data as a block.
+func (w *Writer) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	w.block.Reset()
+
+	if w.gz == nil {
+		w.gz = gzip.NewWriter(&w.block)
+	} else {
+		w.gz.Reset(&w.block)
+	}
+
+	w.gz.Extra = []byte{'B', 'C', 2, 0, 0, 0}
+
+	if _, err := w.gz.Write(w.buf); err != nil {
+		return fmt.Errorf("gzip.Writer.Write: %w", err)
+	}
+
+	if err := w.gz.Close(); err != nil {
+		return fmt.Errorf("gzip.Writer.Close: %w", err)
+	}
+
+	block := w.block.Bytes()
+	if len(block) > maxBlockSize {
+		// unreachable with BlockSize of data, since deflate stores incompressible data with little overhead
+		return fmt.Errorf("%w: compressed block of %d bytes", errBlockTooLarge, len(block))
+	}
+
+	binary.LittleEndian.PutUint16(block[bsizeOffset:], uint16(len(block)-1)) //nolint:gosec
+
+	if _, err := w.w.Write(block); err != nil {
+		return fmt.Errorf("Write: %w", err)
+	}
+
+	w.buf = w.buf[:0]
+
+	return nil
+}
+
+// Close writes the buffered data and the end-of-file block.
+// It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	if _, err := w.w.Write(eofBlock); err != nil {
+		return fmt.Errorf("Write: %w", err)
+	}
+
+	return nil
+}
+
+// Reader decompresses the whole file sequentially,
+// recording blocks so that ReaderAt can read them later.
+type Reader struct {
+	counter  *countingReader
+	gz       *gzip.Reader
+	inMember bool
+	blocks   []Block
+	offset   int64
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{counter: &countingReader{r: r}}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for {
+		if !r.inMember {
+			if err := r.nextMember(); err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := r.gz.Read(p)
+		r.offset += int64(n)
+
+		if errors.Is(err, io.EOF) {
+			r.inMember = false
+			err = nil
+		}
+
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+// nextMember starts reading the next gzip member, and returns io.EOF at the end of the file.
+func (r *Reader) nextMember() error {
+	if _, err := r.counter.peek(); err != nil {
+		return err
+	}
+
+	r.blocks = append(r.blocks, Block{r.counter.n, r.offset})
+
+	var err error
+
+	if r.gz == nil {
+		r.gz, err = gzip.NewReader(r.counter)
+	} else {
+		err = r.gz.Reset(r.counter)
+	}
+
+	if err != nil {
+		return fmt.Errorf("gzip.Reader: %w", err)
+	}
+
+	r.gz.Multistream(false)
+	r.inMember = true
+
+	return nil
+}
+
+// Blocks returns the blocks read so far, all blocks after Read returned io.EOF.
+// Empty blocks such as the end-of-file block are omitted.
+func (r *Reader) Blocks() []Block {
+	blocks := make([]Block, 0, len(r.blocks))
+
+	for i, block := range r.blocks {
+		if i+1 < len(r.blocks) && r.blocks[i+1].UncompressedOffset == block.UncompressedOffset {
+			continue
+		}
+
+		if i+1 == len(r.blocks) && block.UncompressedOffset == r.offset {
+			continue
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	return blocks
+}
+
+// Size returns the uncompressed size read so far.
+func (r *Reader) Size() int64 {
+	return r.offset
+}
+
+// countingReader counts consumed bytes. It implements io.ByteReader,
+// so that gzip.Reader does not read ahead beyond the end of a member.
+type countingReader struct {
+	r    io.Reader
+	buf  [4096]byte
+	head int
+	tail int
+	n    int64
+}
+
+func (c *countingReader) fill() error {
+	if c.head < c.tail {
+		return nil
+	}
+
+	n, err := c.r.Read(c.buf[:])
+	c.head, c.tail = 0, n
+
+	if n > 0 {
+		return nil
+	}
+
+	if err == nil {
+		err = io.ErrNoProgress
+	}
+
+	return err
+}
+
+func (c *countingReader) peek() (byte, error) {
+	if err := c.fill(); err != nil {
+		return 0, err
+	}
+
+	return c.buf[c.head], nil
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	if err := c.fill(); err != nil {
+		return 0, err
+	}
+
+	n := copy(p, c.buf[c.head:c.tail])
+	c.head += n
+	c.n += int64(n)
+
+	return n, nil
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.peek()
+	if err != nil {
+		return 0, err
+	}
+
+	c.head++
+	c.n++
+
+	return b, nil
+}
+
+// ReaderAt reads the uncompressed stream at any offset, decompressing only the blocks read.
+// It is safe for concurrent use, and keeps a few recently decompressed blocks.
+// Concurrent reads of the same block decompress it once.
+type ReaderAt struct {
+	r              io.ReaderAt
+	blocks         []Block
+	compressedSize int64
+	size           int64
+
+	mu       sync.Mutex
+	recent   []cachedBlock // most recently used last
+	inflight map[int]*inflightBlock
+}
+
+type cachedBlock struct {
+	index int
+	data  []byte
+}
+
+// inflightBlock is a block being decompressed, which other readers of the block wait for.
+type inflightBlock struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+const recentBlocks = 8
+
+// NewReaderAt returns a ReaderAt of the compressed file r of compressedSize,
+// with blocks and the uncompressed size recorded by Reader.
+func NewReaderAt(r io.ReaderAt, blocks []Block, compressedSize, size int64) *ReaderAt {
+	return &ReaderAt{
+		r:              r,
+		blocks:         blocks,
+		compressedSize: compressedSize,
+		size:           size,
+		inflight:       make(map[int]*inflightBlock),
+	}
+}
+
+func (r *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	n := 0
+
+	for n < len(p) && off < r.size {
+		// the last block which starts at or before off
+		i := sort.Search(len(r.blocks), func(i int) bool {
+			return r.blocks[i].UncompressedOffset > off
+		}) - 1
+		if i < 0 {
+			return n, fmt.Errorf("%w: no block at offset %d", errCorrupted, off)
+		}
+
+		data, err := r.block(i)
+		if err != nil {
+			return n, err
+		}
+
+		copied := copy(p[n:], data[off-r.blocks[i].UncompressedOffset:])
+		if copied == 0 {
+			return n, fmt.Errorf("%w: block %d is shorter than expected", errCorrupted, i)
+		}
+
+		n += copied
+		off += int64(copied)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+var errCorrupted = errors.New("compressed file does not match blocks")
+
+// block returns the decompressed data of the block.
+func (r *ReaderAt) block(i int) ([]byte, error) {
+	r.mu.Lock()
+	for j, cached := range r.recent {
+		if cached.index == i {
+			copy(r.recent[j:], r.recent[j+1:])
+			r.recent[len(r.recent)-1] = cached
+			r.mu.Unlock()
+
+			return cached.data, nil
+		}
+	}
+
+	if call, ok := r.inflight[i]; ok {
+		r.mu.Unlock()
+		<-call.done
+
+		return call.data, call.err
+	}
+
+	call := &inflightBlock{done: make(chan struct{})}
+	r.inflight[i] = call
+	r.mu.Unlock()
+
+	call.data, call.err = r.decompress(i)
+
+	r.mu.Lock()
+	delete(r.inflight, i)
+
+	if call.err == nil {
+		if len(r.recent) == recentBlocks {
+			r.recent = r.recent[1:]
+		}
+
+		r.recent = append(r.recent, cachedBlock{i, call.data})
+	}
+	r.mu.Unlock()
+
+	close(call.done)
+
+	return call.data, call.err
+}
+
+// decompress reads and decompresses the block.
+func (r *ReaderAt) decompress(i int) ([]byte, error) {
+	limit := r.compressedSize
+	if i+1 < len(r.blocks) {
+		limit = r.blocks[i+1].CompressedOffset
+	}
+
+	section := io.NewSectionReader(r.r, r.blocks[i].CompressedOffset, limit-r.blocks[i].CompressedOffset)
+
+	gz, err := gzip.NewReader(section)
+	if err != nil {
+		return nil, fmt.Errorf("gzip.NewReader: %w", err)
+	}
+
+	gz.Multistream(false)
+
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,314 @@
+package bgzf_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yokomotod/yuccadb/bgzf"
+)
+
+func testData(n int) []byte {
+	var buf bytes.Buffer
+	for i := range n {
+		fmt.Fprintf(&buf, "key%08d,value%d\n", i, i*i)
+	}
+
+	return buf.Bytes()
+}
+
+func compress(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	w := bgzf.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// readAll reads the compressed file with Reader and returns a ReaderAt of it.
+func readAll(t *testing.T, compressed, want []byte) (*bgzf.ReaderAt, []bgzf.Block) {
+	t.Helper()
+
+	r := bgzf.NewReader(bytes.NewReader(compressed))
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, want) {
+		t.Fatalf("uncompressed %d bytes, want %d bytes", len(data), len(want))
+	}
+
+	if r.Size() != int64(len(want)) {
+		t.Fatalf("size %d, want %d", r.Size(), len(want))
+	}
+
+	blocks := r.Blocks()
+
+	return bgzf.NewReaderAt(bytes.NewReader(compressed), blocks, int64(len(compressed)), r.Size()), blocks
+}
+
+func TestIncompressibleBlocks(t *testing.T) {
+	t.Parallel()
+
+	data := make([]byte, 3*bgzf.BlockSize)
+	if _, err := rand.New(rand.NewSource(1)).Read(data); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+
+	compressed := compress(t, data)
+
+	// walk the members by BSIZE as other BGZF tools do
+	members := 0
+
+	for offset := 0; offset < len(compressed); members++ {
+		member := compressed[offset:]
+		if len(member) < 18 || member[12] != 'B' || member[13] != 'C' {
+			t.Fatalf("no BC extra field at %d", offset)
+		}
+
+		size := int(binary.LittleEndian.Uint16(member[16:])) + 1
+
+		if length := memberLength(t, member); size != length {
+			t.Fatalf("member %d at %d: BSIZE + 1 is %d, but the member is %d bytes", members, offset, size, length)
+		}
+
+		offset += size
+	}
+
+	// data blocks and the end-of-file block
+	if want := 3 + 1; members != want {
+		t.Fatalf("%d members, want %d", members, want)
+	}
+
+	readAll(t, compressed, data)
+}
+
+// memberLength returns the length of the gzip member at the start of buf.
+func memberLength(t *testing.T, buf []byte) int {
+	t.Helper()
+
+	counter := &countingByteReader{r: bytes.NewReader(buf)}
+
+	r, err := gzip.NewReader(counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Multistream(false)
+
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatal(err)
+	}
+
+	return counter.n
+}
+
+// countingByteReader counts the bytes read, and implements io.ByteReader
+// so that gzip.Reader does not read ahead of the member.
+type countingByteReader struct {
+	r *bytes.Reader
+	n int
+}
+
+func (c *countingByteReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+
+	return n, err //nolint:wrapcheck
+}
+
+func (c *countingByteReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+
+	return b, err //nolint:wrapcheck
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	data := testData(20_000)
+	compressed := compress(t, data)
+
+	// readable by gzip as concatenated members
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, data) {
+		t.Fatal("gzip.Reader read different data")
+	}
+
+	ra, blocks := readAll(t, compressed, data)
+
+	wantBlocks := (len(data) + bgzf.BlockSize - 1) / bgzf.BlockSize
+	if len(blocks) != wantBlocks {
+		t.Fatalf("%d blocks, want %d", len(blocks), wantBlocks)
+	}
+
+	cases := []struct {
+		name   string
+		offset int64
+		length int
+	}{
+		{"head", 0, 100},
+		{"within block", bgzf.BlockSize + 10, 100},
+		{"across blocks", bgzf.BlockSize - 50, 100},
+		{"across several blocks", 10, 3 * bgzf.BlockSize},
+		{"tail", int64(len(data) - 10), 10},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := make([]byte, c.length)
+			if _, err := ra.ReadAt(buf, c.offset); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(buf, data[c.offset:c.offset+int64(c.length)]) {
+				t.Fatalf("unexpected data at %d", c.offset)
+			}
+		})
+	}
+
+	buf := make([]byte, 20)
+
+	n, err := ra.ReadAt(buf, int64(len(data)-10))
+	if n != 10 || err != io.EOF { //nolint:errorlint
+		t.Fatalf("expected 10 bytes and io.EOF at the end, but got %d, %v", n, err)
+	}
+}
+
+func TestPlainGzip(t *testing.T) {
+	t.Parallel()
+
+	data := testData(1_000)
+
+	// two members, as written by concatenating gzip files
+	var buf bytes.Buffer
+	for _, part := range [][]byte{data[:1000], data[1000:]} {
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(part); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ra, blocks := readAll(t, buf.Bytes(), data)
+
+	if len(blocks) != 2 || blocks[1].UncompressedOffset != 1000 {
+		t.Fatalf("unexpected blocks: %v", blocks)
+	}
+
+	got := make([]byte, 100)
+	if _, err := ra.ReadAt(got, 950); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, data[950:1050]) {
+		t.Fatal("unexpected data across members")
+	}
+}
+
+// slowReaderAt counts reads, and makes them slow so that concurrent readers overlap.
+type slowReaderAt struct {
+	r     io.ReaderAt
+	reads atomic.Int64
+}
+
+func (r *slowReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.reads.Add(1)
+	time.Sleep(time.Millisecond)
+
+	return r.r.ReadAt(p, off) //nolint:wrapcheck
+}
+
+func TestConcurrentBlockReads(t *testing.T) {
+	t.Parallel()
+
+	data := testData(1_000)
+	compressed := compress(t, data)
+	_, blocks := readAll(t, compressed, data)
+
+	readBlock := func(ra *bgzf.ReaderAt) error {
+		buf := make([]byte, 100)
+		if _, err := ra.ReadAt(buf, 100); err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if !bytes.Equal(buf, data[100:200]) {
+			return errors.New("unexpected data")
+		}
+
+		return nil
+	}
+
+	// reads to decompress the block once
+	once := &slowReaderAt{r: bytes.NewReader(compressed)}
+	if err := readBlock(bgzf.NewReaderAt(once, blocks, int64(len(compressed)), int64(len(data)))); err != nil {
+		t.Fatal(err)
+	}
+
+	concurrent := &slowReaderAt{r: bytes.NewReader(compressed)}
+	ra := bgzf.NewReaderAt(concurrent, blocks, int64(len(compressed)), int64(len(data)))
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, 8)
+
+	for range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			errs <- readBlock(ra)
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if concurrent.reads.Load() != once.reads.Load() {
+		t.Fatalf("expected the block to be decompressed once with %d reads, but got %d reads",
+			once.reads.Load(), concurrent.reads.Load())
+	}
+}
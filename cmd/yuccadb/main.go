@@ -0,0 +1,143 @@
+// Command yuccadb provides tools for yuccadb data files.
+//
+// Usage:
+//
+//	yuccadb validate [flags] FILE
+//
+// validate reads the whole data file and prints every problem of its rows and a summary.
+// It exits with status 1 if any problems are found, and 2 on usage or read errors.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+const (
+	exitProblems = 1
+	exitError    = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "usage: yuccadb validate [flags] FILE")
+
+		return exitError
+	}
+
+	switch args[0] {
+	case "validate":
+		return validate(args[1:], stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+
+		return exitError
+	}
+}
+
+var errInvalidFlag = errors.New("invalid flag")
+
+func validate(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var (
+		opts       yuccaTable.BuildOptions
+		format     = flags.String("format", "auto", "format of the file: auto, csv or jsonl")
+		delimiter  = flags.String("delimiter", ",", "field delimiter of CSV")
+		duplicates = flags.Bool("reject-duplicates", false, "report duplicate keys")
+		quiet      = flags.Bool("quiet", false, "print only the summary")
+	)
+
+	flags.BoolVar(&opts.Header, "header", false, "the first row is a header")
+	flags.IntVar(&opts.KeyColumn, "key-column", 0, "0-based column of the key")
+	flags.StringVar(&opts.KeyField, "key-field", "", `field of the key in JSONL objects (default "key")`)
+	flags.BoolVar(&opts.LazyQuotes, "lazy-quotes", false, "allow quotes in unquoted fields")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: yuccadb validate [flags] FILE")
+
+		return exitError
+	}
+
+	if err := parseOptions(&opts, *format, *delimiter, *duplicates); err != nil {
+		fmt.Fprintln(stderr, err)
+
+		return exitError
+	}
+
+	report, err := yuccaTable.Validate(flags.Arg(0), opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+
+		return exitError
+	}
+
+	if !*quiet {
+		for _, problem := range report.Problems {
+			fmt.Fprintln(stdout, problem)
+		}
+
+		if report.Truncated {
+			fmt.Fprintf(stdout, "... and %d more problems\n", problemCount(report)-len(report.Problems))
+		}
+	}
+
+	fmt.Fprintln(stdout, report.Summary())
+
+	if !report.OK() {
+		return exitProblems
+	}
+
+	return 0
+}
+
+func parseOptions(opts *yuccaTable.BuildOptions, format, delimiter string, duplicates bool) error {
+	f, ok := yuccaTable.ParseFormat(format)
+	if !ok {
+		return fmt.Errorf("%w: unknown format %q", errInvalidFlag, format)
+	}
+
+	// other formats are parsed, but Validate reads only rows of text formats
+	if f != yuccaTable.FormatAuto && f != yuccaTable.FormatCSV && f != yuccaTable.FormatJSONL {
+		return fmt.Errorf("%w: format must be auto, csv or jsonl: %q", errInvalidFlag, format)
+	}
+
+	opts.Format = f
+
+	r, size := utf8.DecodeRuneInString(delimiter)
+	if size == 0 || size != len(delimiter) {
+		return fmt.Errorf("%w: delimiter must be a character: %q", errInvalidFlag, delimiter)
+	}
+
+	opts.Delimiter = r
+
+	if duplicates {
+		opts.Duplicates = yuccaTable.DuplicateReject
+	}
+
+	return nil
+}
+
+func problemCount(report yuccaTable.Report) int {
+	n := 0
+	for _, count := range report.Counts {
+		n += int(count)
+	}
+
+	return n
+}
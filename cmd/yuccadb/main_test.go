@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.tsv")
+	bad := filepath.Join(dir, "bad.csv")
+
+	if err := os.WriteFile(good, []byte("a\t1\nb\t2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(bad, []byte("a,1\nb,\"2\nc,3\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+		want string
+	}{
+		{"ok", []string{"validate", "-delimiter", "\t", good}, 0, "2 rows, 0 bad rows\n"},
+		{"problems", []string{"validate", bad}, exitProblems, "line 2 (offset 4): malformed: "},
+		{"quiet", []string{"validate", "-quiet", bad}, exitProblems, "2 rows, 1 bad rows: 1 malformed\n"},
+		{"missing file", []string{"validate", filepath.Join(dir, "missing.csv")}, exitError, ""},
+		{"unknown format", []string{"validate", "-format", "xml", good}, exitError, ""},
+		{"unknown command", []string{"serve"}, exitError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var stdout, stderr bytes.Buffer
+
+			if code := run(tt.args, &stdout, &stderr); code != tt.code {
+				t.Fatalf("expected exit code %d, but got %d: %s", tt.code, code, stderr.String())
+			}
+
+			if !strings.Contains(stdout.String(), tt.want) {
+				t.Fatalf("expected output containing %q, but got %q", tt.want, stdout.String())
+			}
+		})
+	}
+}
+
+func TestValidateUnsupportedFormat(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "test.csv")
+	if err := os.WriteFile(file, []byte("a,1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"sstable", "parquet"} {
+		var stdout, stderr bytes.Buffer
+
+		if code := run([]string{"validate", "-format", format, file}, &stdout, &stderr); code != exitError {
+			t.Fatalf("%s: expected exit code %d, but got %d", format, exitError, code)
+		}
+
+		if want := "invalid flag: format must be auto, csv or jsonl"; !strings.Contains(stderr.String(), want) {
+			t.Fatalf("%s: expected error containing %q, but got %q", format, want, stderr.String())
+		}
+	}
+}
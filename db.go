@@ -3,7 +3,6 @@ package yuccadb
 import (
 	"errors"
 	"fmt"
-	"os"
 	"sync"
 	"time"
 
@@ -12,14 +11,22 @@ import (
 )
 
 type YuccaDB struct {
-	tables map[string]*yuccaTable.Table
-	mu     sync.RWMutex
-	Logger logger.Logger
+	tables map[string]*tableHandle
+	// replaced tables waiting for their readers to finish, by file
+	retired    map[string]*tableHandle
+	blockCache *yuccaTable.BlockCache
+	mu         sync.RWMutex
+	Logger     logger.Logger
+	// OnSchemaChange is called by PutTable before replacing a table whose columns, schema or
+	// number of fields differ from the new table, e.g. RejectSchemaChange.
+	// If it returns an error, PutTable returns it and keeps the old table.
+	OnSchemaChange func(change SchemaChange) error
 }
 
 func NewYuccaDB() *YuccaDB {
 	db := &YuccaDB{
-		tables: make(map[string]*yuccaTable.Table),
+		tables:  make(map[string]*tableHandle),
+		retired: make(map[string]*tableHandle),
 		Logger: &logger.DefaultLogger{
 			Level: logger.Warning,
 		},
@@ -28,16 +35,90 @@ func NewYuccaDB() *YuccaDB {
 	return db
 }
 
+// EnableBlockCache enables an in-process cache of parsed rows shared by tables,
+// bounded by maxBytes. It applies to tables put after the call.
+func (db *YuccaDB) EnableBlockCache(maxBytes int64) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.blockCache = yuccaTable.NewBlockCache(maxBytes)
+}
+
+// BlockCacheStats returns hit/miss counters of the block cache,
+// and false if the block cache is not enabled.
+func (db *YuccaDB) BlockCacheStats() (yuccaTable.CacheStats, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.blockCache == nil {
+		return yuccaTable.CacheStats{}, false
+	}
+
+	return db.blockCache.Stats(), true
+}
+
 func (db *YuccaDB) TableTimestamp(tableName string) (time.Time, bool) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	table, ok := db.tables[tableName]
+	handle, ok := db.tables[tableName]
 	if !ok {
 		return time.Time{}, false
 	}
 
-	return table.Timestamp(), true
+	return handle.table.Timestamp(), true
+}
+
+// TableStats returns the statistics of the table.
+func (db *YuccaDB) TableStats(tableName string) (yuccaTable.Stats, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	handle, ok := db.tables[tableName]
+	if !ok {
+		return yuccaTable.Stats{}, false
+	}
+
+	return handle.table.Stats(), true
+}
+
+// TableColumns returns the column names of the table including the key column,
+// which is nil if the table has neither a header nor explicit column names,
+// or its format does not name columns.
+func (db *YuccaDB) TableColumns(tableName string) ([]string, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	handle, ok := db.tables[tableName]
+	if !ok {
+		return nil, false
+	}
+
+	table, ok := handle.table.(columnLister)
+	if !ok {
+		return nil, true
+	}
+
+	return table.Columns(), true
+}
+
+// TableSchema returns the column types of the table in the order of TableColumns,
+// which is nil unless the table has the schema option or is a Parquet file.
+func (db *YuccaDB) TableSchema(tableName string) (yuccaTable.Schema, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	handle, ok := db.tables[tableName]
+	if !ok {
+		return nil, false
+	}
+
+	table, ok := handle.table.(schemaReader)
+	if !ok {
+		return nil, true
+	}
+
+	return table.Schema(), true
 }
 
 func (db *YuccaDB) validatePutTable(tableName, file string, replace bool) error {
@@ -45,67 +126,119 @@ func (db *YuccaDB) validatePutTable(tableName, file string, replace bool) error
 		return fmt.Errorf("table %q already exists and replace is false", tableName)
 	}
 
-	for _, table := range db.tables {
-		if table.File() == file {
+	for _, handle := range db.tables {
+		if handle.table.File() == file {
 			return fmt.Errorf("file %q is already used by table %q", file, tableName)
 		}
 	}
 
+	if _, ok := db.retired[file]; ok {
+		return fmt.Errorf("file %q is still used by a replaced table", file)
+	}
+
 	return nil
 }
 
-func (db *YuccaDB) PutTable(tableName, file string, replace bool) error {
+// PutTable loads the data file as the table, in the format selected by the extension or WithFormat.
+// If replace is true, an existing table is replaced, and its files are removed after in-flight readers finish.
+// A change of the schema is logged and checked by OnSchemaChange before the replacement.
+// If the table is not put, the files written next to the data file for it, e.g. the index file, are removed.
+func (db *YuccaDB) PutTable(tableName, file string, replace bool, options ...TableOption) error {
 	db.mu.RLock()
-	// pre-validate before heavy BuildTable process
+	// pre-validate before heavy OpenTable process
 	err := db.validatePutTable(tableName, file, replace)
+	blockCache := db.blockCache
 	db.mu.RUnlock()
 
 	if err != nil {
 		return err
 	}
 
-	table, err := yuccaTable.BuildTable(file, db.Logger)
+	opts := yuccaTable.BuildOptions{BlockCache: blockCache}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	table, err := yuccaTable.OpenTable(file, db.Logger, opts)
 	if err != nil {
-		return fmt.Errorf("table.BuildTable: %w", err)
+		return fmt.Errorf("table.OpenTable: %w", err)
 	}
 
-	db.mu.Lock()
-	// re-validate with lock
-	if err := db.validatePutTable(tableName, file, replace); err != nil {
-		db.mu.Unlock()
+	for {
+		db.mu.RLock()
+		oldHandle := db.tables[tableName]
+		db.mu.RUnlock()
 
-		return err
-	}
+		// OnSchemaChange is called without the lock, so that it may use the DB
+		if err := db.checkSchemaChange(tableName, oldHandle, table); err != nil {
+			db.discard(table)
 
-	oldTable, hadOldTable := db.tables[tableName]
-	db.tables[tableName] = table
-	db.mu.Unlock()
+			return err
+		}
+
+		db.mu.Lock()
+		// re-validate with lock
+		if err := db.validatePutTable(tableName, file, replace); err != nil {
+			db.mu.Unlock()
+			db.discard(table)
+
+			return err
+		}
+
+		if db.tables[tableName] != oldHandle {
+			// replaced meanwhile, so check the schema against the new one
+			db.mu.Unlock()
+
+			continue
+		}
+
+		db.tables[tableName] = newTableHandle(table)
+
+		if oldHandle != nil {
+			db.retired[oldHandle.table.File()] = oldHandle
+		}
+		db.mu.Unlock()
+
+		if oldHandle != nil {
+			// old table files are removed after in-flight readers finish
+			db.release(oldHandle)
+		}
 
-	if !hadOldTable {
 		return nil
 	}
+}
 
-	db.Logger.Debugf("Remove old table file: %q\n", oldTable.File())
+// DropTable removes the table and its files.
+// Files are removed after in-flight readers finish.
+func (db *YuccaDB) DropTable(tableName string) error {
+	db.mu.Lock()
+
+	handle, ok := db.tables[tableName]
+	if !ok {
+		db.mu.Unlock()
 
-	if err = os.Remove(oldTable.File()); err != nil {
-		return fmt.Errorf("os.Remove(%q): %w", oldTable.File(), err)
+		return ErrTableNotFound
 	}
 
+	delete(db.tables, tableName)
+	db.retired[handle.table.File()] = handle
+	db.mu.Unlock()
+
+	db.release(handle)
+
 	return nil
 }
 
 var ErrTableNotFound = errors.New("table not found")
 
 func (db *YuccaDB) GetValue(tableName, key string) (yuccaTable.Result, error) {
-	db.mu.RLock()
-	table, tableExists := db.tables[tableName]
-	db.mu.RUnlock()
-
-	if !tableExists {
-		return yuccaTable.Result{}, ErrTableNotFound
+	handle, err := db.acquire(tableName)
+	if err != nil {
+		return yuccaTable.Result{}, err
 	}
+	defer db.release(handle)
 
-	res, err := table.Get(key)
+	res, err := handle.table.Get(key)
 	if err != nil {
 		return yuccaTable.Result{}, fmt.Errorf("table.Get: %w", err)
 	}
@@ -114,18 +247,215 @@ func (db *YuccaDB) GetValue(tableName, key string) (yuccaTable.Result, error) {
 }
 
 func (db *YuccaDB) BulkGetValues(tableName string, keys []string) (yuccaTable.BulkResult, error) {
-	db.mu.RLock()
-	table, tableExists := db.tables[tableName]
-	db.mu.RUnlock()
-
-	if !tableExists {
-		return yuccaTable.BulkResult{}, ErrTableNotFound
+	handle, err := db.acquire(tableName)
+	if err != nil {
+		return yuccaTable.BulkResult{}, err
 	}
+	defer db.release(handle)
 
-	res, err := table.BulkGet(keys)
+	res, err := handle.table.BulkGet(keys)
 	if err != nil {
 		return yuccaTable.BulkResult{}, fmt.Errorf("table.Get: %w", err)
 	}
 
 	return res, nil
 }
+
+// ScanValues returns rows whose keys are in [start, end) in ascending order.
+// Empty end means no upper bound, and limit <= 0 means no limit.
+func (db *YuccaDB) ScanValues(tableName, start, end string, limit int) (yuccaTable.ScanResult, error) {
+	handle, err := db.acquire(tableName)
+	if err != nil {
+		return yuccaTable.ScanResult{}, err
+	}
+	defer db.release(handle)
+
+	res, err := handle.table.Scan(start, end, limit)
+	if err != nil {
+		return yuccaTable.ScanResult{}, fmt.Errorf("table.Scan: %w", err)
+	}
+
+	return res, nil
+}
+
+// GetTuple is like GetValue, but takes the parts of a composite key instead of the joined key.
+func (db *YuccaDB) GetTuple(tableName string, parts []string) (yuccaTable.Result, error) {
+	handle, err := db.acquire(tableName)
+	if err != nil {
+		return yuccaTable.Result{}, err
+	}
+	defer db.release(handle)
+
+	table, err := as[tupleGetter](handle.table, "GetTuple")
+	if err != nil {
+		return yuccaTable.Result{}, err
+	}
+
+	res, err := table.GetTuple(parts)
+	if err != nil {
+		return yuccaTable.Result{}, fmt.Errorf("table.GetTuple: %w", err)
+	}
+
+	return res, nil
+}
+
+// GetAll returns all rows for key of a table with yuccaTable.DuplicateMulti.
+func (db *YuccaDB) GetAll(tableName, key string) ([]yuccaTable.Result, error) {
+	handle, err := db.acquire(tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer db.release(handle)
+
+	table, err := as[multiGetter](handle.table, "GetAll")
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := table.GetAll(key)
+	if err != nil {
+		return nil, fmt.Errorf("table.GetAll: %w", err)
+	}
+
+	return res, nil
+}
+
+// GetColumns is like GetValue, but returns only the columns in the order.
+func (db *YuccaDB) GetColumns(tableName, key string, columns []string) (yuccaTable.Result, error) {
+	handle, err := db.acquire(tableName)
+	if err != nil {
+		return yuccaTable.Result{}, err
+	}
+	defer db.release(handle)
+
+	table, err := as[columnGetter](handle.table, "GetColumns")
+	if err != nil {
+		return yuccaTable.Result{}, err
+	}
+
+	res, err := table.GetColumns(key, columns)
+	if err != nil {
+		return yuccaTable.Result{}, fmt.Errorf("table.GetColumns: %w", err)
+	}
+
+	return res, nil
+}
+
+// BulkGetColumns is like BulkGetValues, but returns only the columns in the order.
+func (db *YuccaDB) BulkGetColumns(tableName string, keys, columns []string) (yuccaTable.BulkResult, error) {
+	handle, err := db.acquire(tableName)
+	if err != nil {
+		return yuccaTable.BulkResult{}, err
+	}
+	defer db.release(handle)
+
+	table, err := as[columnGetter](handle.table, "BulkGetColumns")
+	if err != nil {
+		return yuccaTable.BulkResult{}, err
+	}
+
+	res, err := table.BulkGetColumns(keys, columns)
+	if err != nil {
+		return yuccaTable.BulkResult{}, fmt.Errorf("table.BulkGetColumns: %w", err)
+	}
+
+	return res, nil
+}
+
+// ScanColumns is like ScanValues, but returns only the columns in the order.
+func (db *YuccaDB) ScanColumns(
+	tableName, start, end string, limit int, columns []string,
+) (yuccaTable.ScanResult, error) {
+	handle, err := db.acquire(tableName)
+	if err != nil {
+		return yuccaTable.ScanResult{}, err
+	}
+	defer db.release(handle)
+
+	table, err := as[columnGetter](handle.table, "ScanColumns")
+	if err != nil {
+		return yuccaTable.ScanResult{}, err
+	}
+
+	res, err := table.ScanColumns(start, end, limit, columns)
+	if err != nil {
+		return yuccaTable.ScanResult{}, fmt.Errorf("table.ScanColumns: %w", err)
+	}
+
+	return res, nil
+}
+
+// ReverseScanValues is like ScanValues, but returns rows in descending order.
+func (db *YuccaDB) ReverseScanValues(tableName, start, end string, limit int) (yuccaTable.ScanResult, error) {
+	handle, err := db.acquire(tableName)
+	if err != nil {
+		return yuccaTable.ScanResult{}, err
+	}
+	defer db.release(handle)
+
+	table, err := as[reverseScanner](handle.table, "ReverseScan")
+	if err != nil {
+		return yuccaTable.ScanResult{}, err
+	}
+
+	res, err := table.ReverseScan(start, end, limit)
+	if err != nil {
+		return yuccaTable.ScanResult{}, fmt.Errorf("table.ReverseScan: %w", err)
+	}
+
+	return res, nil
+}
+
+// PrefixScan returns an iterator over rows whose keys start with prefix.
+// The iterator must be closed after use.
+func (db *YuccaDB) PrefixScan(tableName, prefix string) (*Iterator, error) {
+	return db.newIterator(tableName, func(table yuccaTable.TableReader) (*yuccaTable.Iterator, error) {
+		opener, err := as[iterable](table, "PrefixScan")
+		if err != nil {
+			return nil, err
+		}
+
+		it, err := opener.PrefixScan(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("table.PrefixScan: %w", err)
+		}
+
+		return it, nil
+	})
+}
+
+// IterValues returns an iterator over rows whose keys are in [start, end).
+// Empty end means no upper bound. The iterator must be closed after use.
+func (db *YuccaDB) IterValues(tableName, start, end string) (*Iterator, error) {
+	return db.newIterator(tableName, func(table yuccaTable.TableReader) (*yuccaTable.Iterator, error) {
+		opener, err := as[iterable](table, "Iter")
+		if err != nil {
+			return nil, err
+		}
+
+		it, err := opener.Iter(start, end)
+		if err != nil {
+			return nil, fmt.Errorf("table.Iter: %w", err)
+		}
+
+		return it, nil
+	})
+}
+
+// BulkIterValues returns an iterator which yields each of the sorted keys in order.
+// The iterator must be closed after use.
+func (db *YuccaDB) BulkIterValues(tableName string, keys []string) (*Iterator, error) {
+	return db.newIterator(tableName, func(table yuccaTable.TableReader) (*yuccaTable.Iterator, error) {
+		opener, err := as[iterable](table, "BulkIter")
+		if err != nil {
+			return nil, err
+		}
+
+		it, err := opener.BulkIter(keys)
+		if err != nil {
+			return nil, fmt.Errorf("table.BulkIter: %w", err)
+		}
+
+		return it, nil
+	})
+}
@@ -9,10 +9,13 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/yokomotod/yuccadb"
+	"github.com/yokomotod/yuccadb/internals/parquettest"
 	"github.com/yokomotod/yuccadb/internals/testdata"
 	"github.com/yokomotod/yuccadb/logger"
+	"github.com/yokomotod/yuccadb/parquet"
 	yuccaTable "github.com/yokomotod/yuccadb/table"
 )
 
@@ -82,6 +85,48 @@ func TestDB(t *testing.T) {
 	}
 }
 
+func TestDBReadModes(t *testing.T) {
+	t.Parallel()
+
+	for _, mode := range []yuccaTable.ReadMode{yuccaTable.ReadModeFile, yuccaTable.ReadModeMmap} {
+		t.Run(mode.String(), func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+
+			tableSize := 10_000
+
+			testFile, err := testdata.GenTestCsv(tempDir, tableSize)
+			if err != nil {
+				t.Fatalf("GenTestCsv: %v", err)
+			}
+
+			db := yuccadb.NewYuccaDB()
+			db.Logger = &logger.DefaultLogger{Level: logger.Warning}
+
+			if err := db.PutTable("test", testFile, false, yuccadb.WithReadMode(mode)); err != nil {
+				t.Fatalf("db.PutTable: %v", err)
+			}
+
+			testDBGetValue(t, db, "test", "0000000999", []string{"999"})
+			testDBGetValue(t, db, "test", fmt.Sprintf("%010d", tableSize-1), []string{strconv.Itoa(tableSize - 1)})
+			testDBGetValue(t, db, "test", "0000000999x", nil)
+			testDBBulkGetValues(
+				t, db, "test", []string{"0000000123", "0000001234"}, [][]string{{"123"}, {"1234"}}, nil,
+			)
+
+			res, err := db.ReverseScanValues("test", "", "", 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(res.Values, [][]string{{strconv.Itoa(tableSize - 1)}}) {
+				t.Fatalf("expected last value, but got %v", res.Values)
+			}
+		})
+	}
+}
+
 func TestDBBulk(t *testing.T) {
 	t.Parallel()
 
@@ -117,6 +162,14 @@ func TestDBBulk(t *testing.T) {
 		{"one key", []string{"0000000000"}, [][]string{{"0"}}, nil},
 		{"some key not found", []string{"0000001234", "0000001xxx"}, [][]string{{"1234"}, nil}, nil},
 		{"all key not found", []string{"0000001xxx", "0000002xxx"}, [][]string{nil, nil}, nil},
+		{
+			"key not found before existing key on same chunk",
+			[]string{"0000001233x", "0000001234"},
+			[][]string{nil, {"1234"}},
+			nil,
+		},
+		{"key out of range", []string{"0000001234", "x"}, [][]string{{"1234"}, nil}, nil},
+		{"all key out of range", []string{"x", "y"}, [][]string{nil, nil}, nil},
 		{"keys not sorted", []string{"0000000001", "0000000000"}, nil, yuccaTable.ErrKeysNotSorted},
 	}
 
@@ -266,3 +319,836 @@ func TestReplaceTable(t *testing.T) {
 		t.Fatalf("expected value, but got %s", res.Values)
 	}
 }
+
+func TestDBScan(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	tableSize := 10_000
+
+	testFile, err := testdata.GenTestCsv(tempDir, tableSize)
+	if err != nil {
+		t.Fatalf("GenTestCsv: %v", err)
+	}
+
+	db := yuccadb.NewYuccaDB()
+	db.Logger = &logger.DefaultLogger{Level: logger.Warning}
+
+	if err := db.PutTable("test", testFile, false); err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	cases := []struct {
+		name       string
+		start, end string
+		limit      int
+		reverse    bool
+		want       []int
+	}{
+		{"within a chunk", "0000000010", "0000000013", 0, false, []int{10, 11, 12}},
+		{"across chunks", "0000000998", "0000001002", 0, false, []int{998, 999, 1000, 1001}},
+		{"start between keys", "0000000010x", "0000000013", 0, false, []int{11, 12}},
+		{"with limit", "0000000998", "", 3, false, []int{998, 999, 1000}},
+		{"until last key", "0000009998", "", 0, false, []int{9998, 9999}},
+		{"out of range", "1", "", 0, false, nil},
+		{"empty range", "0000000005", "0000000005", 0, false, nil},
+		{"reverse across chunks", "0000000998", "0000001002", 0, true, []int{1001, 1000, 999, 998}},
+		{"reverse with limit", "0000000998", "0000001002", 2, true, []int{1001, 1000}},
+		{"reverse from first key", "", "0000000002", 0, true, []int{1, 0}},
+		{"reverse from last key", "", "", 2, true, []int{9999, 9998}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			scan := db.ScanValues
+			if c.reverse {
+				scan = db.ReverseScanValues
+			}
+
+			res, err := scan("test", c.start, c.end, c.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var wantKeys []string
+
+			var wantValues [][]string
+
+			for _, i := range c.want {
+				wantKeys = append(wantKeys, fmt.Sprintf("%010d", i))
+				wantValues = append(wantValues, []string{strconv.Itoa(i)})
+			}
+
+			if !reflect.DeepEqual(res.Keys, wantKeys) {
+				t.Fatalf("expected keys %v, but got %v", wantKeys, res.Keys)
+			}
+
+			if !reflect.DeepEqual(res.Values, wantValues) {
+				t.Fatalf("expected values %v, but got %v", wantValues, res.Values)
+			}
+		})
+	}
+}
+
+func TestDBPrefixScan(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	tableSize := 10_000
+
+	testFile, err := testdata.GenTestCsv(tempDir, tableSize)
+	if err != nil {
+		t.Fatalf("GenTestCsv: %v", err)
+	}
+
+	db := yuccadb.NewYuccaDB()
+	db.Logger = &logger.DefaultLogger{Level: logger.Warning}
+
+	if err := db.PutTable("test", testFile, false); err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	cases := []struct {
+		name      string
+		prefix    string
+		wantFirst int
+		wantCount int
+	}{
+		{"within a chunk", "000000012", 120, 10},
+		{"whole chunk", "0000001", 1000, 1000},
+		{"last keys", "000000999", 9990, 10},
+		{"exact key", "0000000999", 999, 1},
+		{"not found", "0000000999x", 0, 0},
+		{"out of range", "x", 0, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			it, err := db.PrefixScan("test", c.prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer it.Close()
+
+			count := 0
+
+			for it.Next() {
+				want := c.wantFirst + count
+				if it.Key() != fmt.Sprintf("%010d", want) || !reflect.DeepEqual(it.Values(), []string{strconv.Itoa(want)}) {
+					t.Fatalf("expected row %d, but got %q %v", want, it.Key(), it.Values())
+				}
+
+				count++
+			}
+
+			if err := it.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			if count != c.wantCount {
+				t.Fatalf("expected %d rows, but got %d", c.wantCount, count)
+			}
+		})
+	}
+}
+
+func TestReplaceTableWithActiveReader(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	testFile1 := filepath.Join(tempDir, "test_a.csv")
+	testFile2 := filepath.Join(tempDir, "test_b.csv")
+
+	db := yuccadb.NewYuccaDB()
+
+	if err := os.WriteFile(testFile1, []byte("key1,value1\nkey2,value2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.PutTable("test", testFile1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	it, err := db.IterValues("test", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !it.Next() || it.Key() != "key1" {
+		t.Fatalf("expected key1, but got %q (%v)", it.Key(), it.Err())
+	}
+
+	// replace while the iterator is reading the old table
+	if err := os.WriteFile(testFile2, []byte("key1,value3\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.PutTable("test", testFile2, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.PutTable("test2", testFile1, false); err == nil {
+		t.Fatal("expected error for the file of the replaced table")
+	}
+
+	if _, err := os.Stat(testFile1); err != nil {
+		t.Fatalf("old table file should be kept while reading: %v", err)
+	}
+
+	if !it.Next() || it.Key() != "key2" {
+		t.Fatalf("expected key2, but got %q (%v)", it.Key(), it.Err())
+	}
+
+	if err := it.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(testFile1); !os.IsNotExist(err) {
+		t.Fatalf("old table file should be removed after reading: %v", err)
+	}
+
+	res, err := db.GetValue("test", "key1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(res.Values, []string{"value3"}) {
+		t.Fatalf("expected value3, but got %s", res.Values)
+	}
+}
+
+func TestDropTable(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+
+	if err := os.WriteFile(testFile, []byte("key,value\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	db := yuccadb.NewYuccaDB()
+
+	if err := db.PutTable("test", testFile, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.DropTable("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.GetValue("test", "key"); !errors.Is(err, yuccadb.ErrTableNotFound) {
+		t.Fatalf("expected error %q, but got %q", yuccadb.ErrTableNotFound, err)
+	}
+
+	if _, err := os.Stat(testFile); !os.IsNotExist(err) {
+		t.Fatalf("table file should be removed: %v", err)
+	}
+
+	if err := db.DropTable("test"); !errors.Is(err, yuccadb.ErrTableNotFound) {
+		t.Fatalf("expected error %q, but got %q", yuccadb.ErrTableNotFound, err)
+	}
+}
+
+func TestDBBlockCache(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	tableSize := 10_000
+
+	testFile, err := testdata.GenTestCsv(tempDir, tableSize)
+	if err != nil {
+		t.Fatalf("GenTestCsv: %v", err)
+	}
+
+	db := yuccadb.NewYuccaDB()
+	db.Logger = &logger.DefaultLogger{Level: logger.Warning}
+	db.EnableBlockCache(64 << 20)
+
+	if err := db.PutTable("test", testFile, false); err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	testDBGetValue(t, db, "test", "0000000000", []string{"0"})
+	testDBGetValue(t, db, "test", "0000000999", []string{"999"})
+	testDBGetValue(t, db, "test", "0000000999x", nil)
+	testDBGetValue(t, db, "test", fmt.Sprintf("%010d", tableSize-2), []string{strconv.Itoa(tableSize - 2)})
+	testDBGetValue(t, db, "test", fmt.Sprintf("%010d", tableSize-1), []string{strconv.Itoa(tableSize - 1)})
+	testDBBulkGetValues(
+		t, db, "test", []string{"0000000123", "0000001233x", "0000001234", "x"}, [][]string{{"123"}, nil, {"1234"}, nil}, nil,
+	)
+
+	stats, ok := db.BlockCacheStats()
+	if !ok {
+		t.Fatal("block cache is not enabled")
+	}
+
+	// block 0 is read once, and then hit by following lookups
+	if stats.Hits < 3 || stats.Misses == 0 {
+		t.Fatalf("unexpected cache stats: %+v", stats)
+	}
+}
+
+func TestDBBloomFilter(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	tableSize := 10_000
+
+	testFile, err := testdata.GenTestCsv(tempDir, tableSize)
+	if err != nil {
+		t.Fatalf("GenTestCsv: %v", err)
+	}
+
+	db := yuccadb.NewYuccaDB()
+	db.Logger = &logger.DefaultLogger{Level: logger.Warning}
+
+	if err := db.PutTable("test", testFile, false, yuccadb.WithBloomFilter(0.01)); err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	for i := range tableSize {
+		testDBGetValue(t, db, "test", fmt.Sprintf("%010d", i), []string{strconv.Itoa(i)})
+	}
+
+	testDBGetValue(t, db, "test", "0000000999x", nil)
+	testDBBulkGetValues(
+		t, db, "test", []string{"0000000123", "0000001233x", "0000001234"}, [][]string{{"123"}, nil, {"1234"}}, nil,
+	)
+}
+
+func TestDBIndexInterval(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	tableSize := 1_000
+
+	testFile, err := testdata.GenTestCsv(tempDir, tableSize)
+	if err != nil {
+		t.Fatalf("GenTestCsv: %v", err)
+	}
+
+	db := yuccadb.NewYuccaDB()
+	db.Logger = &logger.DefaultLogger{Level: logger.Warning}
+
+	if err := db.PutTable("test", testFile, false, yuccadb.WithIndexInterval(7)); err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	keys := make([]string, 0, tableSize)
+	want := make([][]string, 0, tableSize)
+
+	for i := range tableSize {
+		key := fmt.Sprintf("%010d", i)
+		testDBGetValue(t, db, "test", key, []string{strconv.Itoa(i)})
+
+		keys = append(keys, key)
+		want = append(want, []string{strconv.Itoa(i)})
+	}
+
+	testDBBulkGetValues(t, db, "test", keys, want, nil)
+}
+
+func TestDBColumns(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	testFile, err := testdata.GenTestCsv(tempDir, 100)
+	if err != nil {
+		t.Fatalf("GenTestCsv: %v", err)
+	}
+
+	db := yuccadb.NewYuccaDB()
+	db.Logger = &logger.DefaultLogger{Level: logger.Warning}
+
+	if err := db.PutTable("test", testFile, false, yuccadb.WithColumns("id", "value")); err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	columns, ok := db.TableColumns("test")
+	if !ok || !reflect.DeepEqual(columns, []string{"id", "value"}) {
+		t.Fatalf("unexpected columns: %v, %v", columns, ok)
+	}
+
+	res, err := db.GetValue("test", "0000000042")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := res.Get("value"); !ok || v != "42" {
+		t.Fatalf("expected %q, but got %q, %v", "42", v, ok)
+	}
+
+	if _, ok := db.TableColumns("missing"); ok {
+		t.Fatal("expected missing table")
+	}
+}
+
+func TestDBColumnProjection(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	testFile, err := testdata.GenTestCsv(tempDir, 100)
+	if err != nil {
+		t.Fatalf("GenTestCsv: %v", err)
+	}
+
+	db := yuccadb.NewYuccaDB()
+	db.Logger = &logger.DefaultLogger{Level: logger.Warning}
+
+	if err := db.PutTable("test", testFile, false); err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	if _, err := db.GetColumns("test", "0000000042", []string{"value"}); !errors.Is(err, yuccaTable.ErrNoColumnNames) {
+		t.Fatalf("expected ErrNoColumnNames, but got %v", err)
+	}
+
+	namedFile, err := testdata.GenTestCsv(t.TempDir(), 100)
+	if err != nil {
+		t.Fatalf("GenTestCsv: %v", err)
+	}
+
+	if err := db.PutTable("named", namedFile, false, yuccadb.WithColumns("id", "value")); err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	res, err := db.GetColumns("named", "0000000042", []string{"value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(res.Values, []string{"42"}) {
+		t.Fatalf("unexpected values: %v", res.Values)
+	}
+
+	bulk, err := db.BulkGetColumns("named", []string{"0000000001", "0000000002"}, []string{"value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(bulk.Values, [][]string{{"1"}, {"2"}}) {
+		t.Fatalf("unexpected bulk values: %v", bulk.Values)
+	}
+
+	scan, err := db.ScanColumns("named", "0000000098", "", 0, []string{"value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(scan.Values, [][]string{{"98"}, {"99"}}) {
+		t.Fatalf("unexpected scan values: %v", scan.Values)
+	}
+}
+
+func TestDBCompositeKey(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	if err := os.WriteFile(testFile, []byte("t1,u1,1\nt1,u2,2\nt2,u1,3\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	db := yuccadb.NewYuccaDB()
+	db.Logger = &logger.DefaultLogger{Level: logger.Warning}
+
+	err := db.PutTable("test", testFile, false, yuccadb.WithKeyColumns(0, 1), yuccadb.WithKeySeparator("/"))
+	if err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	testDBGetValue(t, db, "test", "t1/u2", []string{"2"})
+
+	res, err := db.GetTuple("test", []string{"t2", "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(res.Values, []string{"3"}) {
+		t.Fatalf("unexpected values: %v", res.Values)
+	}
+}
+
+func TestDBJSONL(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.jsonl")
+	if err := os.WriteFile(testFile, []byte("{\"key\":1,\"v\":\"x\"}\n{\"key\":2,\"v\":\"y\"}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	db := yuccadb.NewYuccaDB()
+	db.Logger = &logger.DefaultLogger{Level: logger.Warning}
+
+	if err := db.PutTable("test", testFile, false); err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	testDBGetValue(t, db, "test", "2", []string{`{"key":2,"v":"y"}`})
+
+	res, err := db.GetColumns("test", "1", []string{"v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := res.Get("v"); !ok || v != "x" {
+		t.Fatalf("expected %q, but got %q, %v", "x", v, ok)
+	}
+}
+
+func TestDBSSTable(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	tableSize := 10_000
+
+	testFile, err := testdata.GenTestCsv(tempDir, tableSize)
+	if err != nil {
+		t.Fatalf("GenTestCsv: %v", err)
+	}
+
+	db := yuccadb.NewYuccaDB()
+	db.Logger = &logger.DefaultLogger{Level: logger.Warning}
+
+	sstFile := filepath.Join(tempDir, "test.sst")
+
+	if err := yuccaTable.ConvertToSSTable(testFile, sstFile, db.Logger, yuccaTable.BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.PutTable("test", sstFile, false); err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	keys := make([]string, 0, tableSize)
+	want := make([][]string, 0, tableSize)
+
+	for i := range tableSize {
+		key := fmt.Sprintf("%010d", i)
+		testDBGetValue(t, db, "test", key, []string{strconv.Itoa(i)})
+
+		keys = append(keys, key)
+		want = append(want, []string{strconv.Itoa(i)})
+	}
+
+	testDBBulkGetValues(t, db, "test", keys, want, nil)
+}
+
+func TestDBDuplicates(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	if err := os.WriteFile(testFile, []byte("a,1\na,2\nb,3\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	db := yuccadb.NewYuccaDB()
+	db.Logger = &logger.DefaultLogger{Level: logger.Warning}
+
+	if err := db.PutTable("test", testFile, false, yuccadb.WithDuplicates(yuccaTable.DuplicateMulti)); err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	res, err := db.GetAll("test", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 2 || !reflect.DeepEqual(res[1].Values, []string{"2"}) {
+		t.Fatalf("unexpected rows: %v", res)
+	}
+}
+
+func TestDBSort(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+
+	if err := os.WriteFile(testFile, []byte("b,2\na,1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	db := yuccadb.NewYuccaDB()
+
+	if err := db.PutTable("test", testFile, false, yuccadb.WithSort(), yuccadb.WithSortDir(t.TempDir())); err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	testDBGetValue(t, db, "test", "a", []string{"1"})
+
+	if err := db.DropTable("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the sorted file and its index are removed with the data file
+	preparedFile := yuccaTable.PreparedFile(testFile)
+	for _, file := range []string{testFile, preparedFile, yuccaTable.IndexFile(preparedFile)} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Fatalf("expected %q to be removed, but got %v", file, err)
+		}
+	}
+}
+
+func TestDBParquet(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	testFile1 := filepath.Join(tempDir, "test_a.parquet")
+	testFile2 := filepath.Join(tempDir, "test_b.parquet")
+
+	columns := []parquet.Column{
+		{Name: "key", Type: parquet.ByteArray, Kind: parquet.KindString},
+		{Name: "value", Type: parquet.Double},
+	}
+
+	for i, file := range []string{testFile1, testFile2} {
+		f, err := os.Create(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w, err := parquettest.NewWriter(f, columns, parquettest.WriterOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := w.Write([]any{"a", float64(i) + 0.5}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		f.Close()
+	}
+
+	db := yuccadb.NewYuccaDB()
+	db.Logger = &logger.DefaultLogger{Level: logger.Warning}
+
+	if err := db.PutTable("test", testFile1, false); err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	testDBGetValue(t, db, "test", "a", []string{"0.5"})
+
+	schema, _ := db.TableSchema("test")
+	if want := (yuccaTable.Schema{{Name: "key", Type: yuccaTable.TypeString}, {Name: "value", Type: yuccaTable.TypeFloat64}}); !reflect.DeepEqual(schema, want) {
+		t.Fatalf("expected schema %v, but got %v", want, schema)
+	}
+
+	if err := db.PutTable("test", testFile2, true); err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	testDBGetValue(t, db, "test", "a", []string{"1.5"})
+
+	// the old Parquet file and its converted file are removed
+	for _, file := range []string{testFile1, yuccaTable.ConvertedFile(testFile1)} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Fatalf("expected %q to be removed, but got %v", file, err)
+		}
+	}
+}
+
+// memTable is a table of a single row for any key, to test registered formats.
+type memTable struct {
+	file string
+}
+
+func (t *memTable) Get(key string) (yuccaTable.Result, error) {
+	return yuccaTable.Result{Values: []string{"mem:" + key}}, nil
+}
+
+func (t *memTable) BulkGet([]string) (yuccaTable.BulkResult, error) {
+	return yuccaTable.BulkResult{}, nil
+}
+
+func (t *memTable) Scan(string, string, int) (yuccaTable.ScanResult, error) {
+	return yuccaTable.ScanResult{}, nil
+}
+
+func (t *memTable) Stats() yuccaTable.Stats { return yuccaTable.Stats{Format: "mem"} }
+func (t *memTable) Close() error            { return nil }
+func (t *memTable) Timestamp() time.Time    { return time.Time{} }
+func (t *memTable) File() string            { return t.file }
+
+//nolint:gochecknoglobals
+var formatMem = yuccaTable.RegisterFormat("mem", nil,
+	func(file string, _ logger.Logger, _ yuccaTable.BuildOptions) (yuccaTable.TableReader, error) {
+		return &memTable{file: file}, nil
+	})
+
+func TestDBRegisteredFormat(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	memFile := filepath.Join(tempDir, "test.mem")
+	csvFile := filepath.Join(tempDir, "test.csv")
+
+	for _, file := range []string{memFile, csvFile} {
+		if err := os.WriteFile(file, []byte("a,1\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := yuccadb.NewYuccaDB()
+
+	if err := db.PutTable("mem", memFile, false, yuccadb.WithFormat(formatMem)); err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	if err := db.PutTable("csv", csvFile, false); err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	testDBGetValue(t, db, "mem", "a", []string{"mem:a"})
+	testDBGetValue(t, db, "csv", "a", []string{"1"})
+
+	if stats, _ := db.TableStats("mem"); stats.Format != "mem" {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	if stats, _ := db.TableStats("csv"); stats.Format != "csv" || stats.Rows != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	if _, err := db.GetColumns("mem", "a", []string{"v"}); !errors.Is(err, yuccadb.ErrUnsupportedOperation) {
+		t.Fatalf("expected ErrUnsupportedOperation, but got %v", err)
+	}
+
+	if _, err := db.PrefixScan("mem", "a"); !errors.Is(err, yuccadb.ErrUnsupportedOperation) {
+		t.Fatalf("expected ErrUnsupportedOperation, but got %v", err)
+	}
+
+	if columns, ok := db.TableColumns("mem"); !ok || columns != nil {
+		t.Fatalf("unexpected columns: %v, %v", columns, ok)
+	}
+
+	// a table of a registered format is replaced by a built-in one
+	csvFile2 := filepath.Join(tempDir, "test2.csv")
+	if err := os.WriteFile(csvFile2, []byte("a,2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.PutTable("mem", csvFile2, true); err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	testDBGetValue(t, db, "mem", "a", []string{"2"})
+
+	if _, err := os.Stat(memFile); !os.IsNotExist(err) {
+		t.Fatalf("expected %q to be removed, but got %v", memFile, err)
+	}
+}
+
+func TestDBBadRows(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.csv")
+	quarantineFile := filepath.Join(tempDir, "rejected.csv")
+
+	if err := os.WriteFile(testFile, []byte("a,1\nb,x\"y\nc,3\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	db := yuccadb.NewYuccaDB()
+
+	if err := db.PutTable("test", testFile, false); err == nil {
+		t.Fatal("expected error without WithBadRows")
+	}
+
+	err := db.PutTable("test", testFile, false,
+		yuccadb.WithBadRows(yuccaTable.BadRowsQuarantine), yuccadb.WithQuarantineFile(quarantineFile))
+	if err != nil {
+		t.Fatalf("db.PutTable: %v", err)
+	}
+
+	testDBGetValue(t, db, "test", "c", []string{"3"})
+
+	if rejected, err := os.ReadFile(quarantineFile); err != nil || string(rejected) != "b,x\"y\n" {
+		t.Fatalf("unexpected quarantine file: %q, %v", rejected, err)
+	}
+}
+
+func TestDBSchemaChange(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	files := make([]string, 3)
+
+	for i, content := range []string{"a,1\n", "a,2\n", "a,3,x\n"} {
+		files[i] = filepath.Join(tempDir, fmt.Sprintf("test_%d.csv", i))
+		if err := os.WriteFile(files[i], []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var changes []yuccadb.SchemaChange
+
+	db := yuccadb.NewYuccaDB()
+	db.OnSchemaChange = func(change yuccadb.SchemaChange) error {
+		changes = append(changes, change)
+
+		return yuccadb.RejectSchemaChange(change)
+	}
+
+	if err := db.PutTable("test", files[0], false); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats, _ := db.TableStats("test"); stats.Fields != 2 {
+		t.Fatalf("expected 2 fields, but got %d", stats.Fields)
+	}
+
+	// the same schema
+	if err := db.PutTable("test", files[1], true); err != nil {
+		t.Fatal(err)
+	}
+
+	err := db.PutTable("test", files[2], true)
+	if !errors.Is(err, yuccadb.ErrSchemaChanged) {
+		t.Fatalf("expected ErrSchemaChanged, but got %v", err)
+	}
+
+	want := []yuccadb.SchemaChange{{Table: "test", OldFields: 2, NewFields: 3}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("expected changes %v, but got %v", want, changes)
+	}
+
+	if want := `table "test": fields 2 -> 3`; changes[0].String() != want {
+		t.Fatalf("expected %q, but got %q", want, changes[0].String())
+	}
+
+	// the old table is kept
+	testDBGetValue(t, db, "test", "a", []string{"2"})
+
+	// the rejected data file is kept, but not the index file written for it
+	if _, err := os.Stat(files[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(yuccaTable.IndexFile(files[2])); !os.IsNotExist(err) {
+		t.Fatalf("expected index file of the rejected table to be removed, but got %v", err)
+	}
+
+	db.OnSchemaChange = nil
+
+	if err := db.PutTable("test", files[2], true, yuccadb.WithColumns("k", "v", "w")); err != nil {
+		t.Fatal(err)
+	}
+
+	testDBGetValue(t, db, "test", "a", []string{"3", "x"})
+}
@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/yokomotod/yuccadb"
 	"github.com/yokomotod/yuccadb/logger"
+	"github.com/yokomotod/yuccadb/table"
 )
 
 type handler struct {
@@ -17,7 +19,16 @@ type handler struct {
 func (h *handler) get(w http.ResponseWriter, r *http.Request) {
 	tableName, key := r.PathValue("table"), r.PathValue("key")
 
-	res, err := h.db.GetValue(tableName, key)
+	var res table.Result
+	var err error
+
+	// e.g. ?columns=name,score returns only the columns
+	if columns := r.FormValue("columns"); columns != "" {
+		res, err = h.db.GetColumns(tableName, key, strings.Split(columns, ","))
+	} else {
+		res, err = h.db.GetValue(tableName, key)
+	}
+
 	if err != nil {
 		if err == yuccadb.ErrTableNotFound {
 			http.Error(w, fmt.Sprintf("table not found: %q", tableName), http.StatusNotFound)
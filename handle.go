@@ -0,0 +1,148 @@
+package yuccadb
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+// tableHandle counts references to a table.
+// YuccaDB holds one reference while the table is registered, and each reader holds one while reading,
+// so that the table is closed and its files are removed only after the table is replaced or dropped
+// and the last reader has finished.
+type tableHandle struct {
+	table yuccaTable.TableReader
+	refs  atomic.Int64
+}
+
+func newTableHandle(table yuccaTable.TableReader) *tableHandle {
+	handle := &tableHandle{table: table}
+	handle.refs.Store(1)
+
+	return handle
+}
+
+func (db *YuccaDB) acquire(tableName string) (*tableHandle, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	handle, ok := db.tables[tableName]
+	if !ok {
+		return nil, ErrTableNotFound
+	}
+
+	// never resurrects a released handle, since registered handles always hold the reference of YuccaDB
+	handle.refs.Add(1)
+
+	return handle, nil
+}
+
+func (db *YuccaDB) release(handle *tableHandle) {
+	if handle.refs.Add(-1) > 0 {
+		return
+	}
+
+	if err := handle.table.Close(); err != nil {
+		db.Logger.Infof("Failed to close old table: %v\n", err)
+	}
+
+	if err := db.removeTableFiles(handle.table); err != nil {
+		db.Logger.Infof("Failed to remove old table files: %v\n", err)
+	}
+
+	db.mu.Lock()
+	delete(db.retired, handle.table.File())
+	db.mu.Unlock()
+}
+
+func (db *YuccaDB) removeTableFiles(table yuccaTable.TableReader) error {
+	db.Logger.Debugf("Remove old table file: %q\n", table.File())
+
+	if err := os.Remove(table.File()); err != nil {
+		return fmt.Errorf("os.Remove(%q): %w", table.File(), err)
+	}
+
+	return removeDerivedFiles(table.File())
+}
+
+// removeDerivedFiles removes the files which tables write next to the data file.
+func removeDerivedFiles(file string) error {
+	preparedFile := yuccaTable.PreparedFile(file)
+
+	// some may exist depending on the format and options
+	for _, f := range []string{
+		yuccaTable.IndexFile(file), yuccaTable.ConvertedFile(file),
+		preparedFile, yuccaTable.IndexFile(preparedFile),
+	} {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("os.Remove(%q): %w", f, err)
+		}
+	}
+
+	return nil
+}
+
+// discard closes the table which was opened but not put, and removes the files it wrote next to the data file.
+// The data file is left as is, and the files are kept if another table uses the data file meanwhile.
+func (db *YuccaDB) discard(table yuccaTable.TableReader) {
+	if err := table.Close(); err != nil {
+		db.Logger.Infof("Failed to close table: %v\n", err)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.retired[table.File()]; ok {
+		return
+	}
+
+	for _, handle := range db.tables {
+		if handle.table.File() == table.File() {
+			return
+		}
+	}
+
+	if err := removeDerivedFiles(table.File()); err != nil {
+		db.Logger.Infof("Failed to remove table files: %v\n", err)
+	}
+}
+
+// Iterator is a table.Iterator which keeps its table alive until it is closed.
+type Iterator struct {
+	*yuccaTable.Iterator
+	release func()
+}
+
+func (db *YuccaDB) newIterator(
+	tableName string, open func(table yuccaTable.TableReader) (*yuccaTable.Iterator, error),
+) (*Iterator, error) {
+	handle, err := db.acquire(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	it, err := open(handle.table)
+	if err != nil {
+		db.release(handle)
+
+		return nil, err
+	}
+
+	var once sync.Once
+
+	return &Iterator{
+		Iterator: it,
+		release: func() {
+			once.Do(func() { db.release(handle) })
+		},
+	}, nil
+}
+
+func (it *Iterator) Close() error {
+	defer it.release()
+
+	return it.Iterator.Close() //nolint:wrapcheck
+}
@@ -11,6 +11,7 @@ import (
 
 	"cloud.google.com/go/bigquery"
 	"cloud.google.com/go/storage"
+	"github.com/yokomotod/yuccadb/bgzf"
 	"github.com/yokomotod/yuccadb/logger"
 )
 
@@ -37,7 +38,14 @@ type BQHelper struct {
 	GCSPath     string
 	gcsBucket   string
 	gcsPrefix   string
-	Logger      logger.Logger
+	// Header exports tables with a header row, so that values can be accessed by column names.
+	Header bool
+	// FieldDelimiter of exported files, e.g. '\t' for TSV. Default is ','.
+	FieldDelimiter rune
+	// KeepCompressed keeps downloaded files compressed as ".csv.gz",
+	// re-compressed in blocks so that tables decompress only the blocks they read.
+	KeepCompressed bool
+	Logger         logger.Logger
 }
 
 func NewBQHelper(ctx context.Context, downloadDir, gcsPath string) (*BQHelper, error) {
@@ -101,9 +109,12 @@ func (h *BQHelper) extractToGCS(ctx context.Context, projectID, datasetID, table
 	gcsRef := bigquery.NewGCSReference(gcsURI)
 	gcsRef.Compression = bigquery.Gzip
 	gcsRef.DestinationFormat = bigquery.CSV
+	if h.FieldDelimiter != 0 {
+		gcsRef.FieldDelimiter = string(h.FieldDelimiter)
+	}
 
 	extractor := h.BQClient.DatasetInProject(projectID, datasetID).Table(tableID).ExtractorTo(gcsRef)
-	extractor.DisableHeader = true
+	extractor.DisableHeader = !h.Header
 
 	h.Logger.Debugf("Extracting table `%s.%s.%s` to %q\n", projectID, datasetID, tableID, gcsURI)
 
@@ -150,8 +161,20 @@ func (h *BQHelper) downloadCSV(ctx context.Context, gcsObject, destPath string)
 	}
 	defer gr.Close()
 
-	if _, err := io.Copy(f, gr); err != nil {
-		return fmt.Errorf("io.Copy: %v", err)
+	if !h.KeepCompressed {
+		if _, err := io.Copy(f, gr); err != nil {
+			return fmt.Errorf("io.Copy: %v", err)
+		}
+	} else {
+		// exported files are a single gzip member, which cannot be read by blocks
+		bw := bgzf.NewWriter(f)
+		if _, err := io.Copy(bw, gr); err != nil {
+			return fmt.Errorf("io.Copy: %v", err)
+		}
+
+		if err := bw.Close(); err != nil {
+			return fmt.Errorf("bgzf.Writer.Close: %v", err)
+		}
 	}
 
 	h.Logger.Debugf("Downloaded %q to %q\n", gcsObject, destPath)
@@ -42,14 +42,26 @@ func (h *BQHelper) ImportTables(ctx context.Context, db *yuccadb.YuccaDB, tableM
 			h.Logger.Debugf("Table %q - `%s.%s.%s` is not imported yet, start importing (last modified: %s)\n", table.DBTableName, projectID, datasetID, tableID, tableMeta.LastModifiedTime.Format(time.RFC3339))
 		}
 
-		// table name _ timestamp .csv
+		// table name _ timestamp .csv(.gz)
 		filename := table.DBTableName + "_" + tableMeta.LastModifiedTime.Format("20060102150405") + ".csv"
+		if h.KeepCompressed {
+			filename += ".gz"
+		}
 		err = h.DownloadTableCSV(ctx, table.BQFullTableID, filename)
 		if err != nil {
 			return fmt.Errorf("DownloadTableCSV: %w", err)
 		}
 
-		db.PutTable(table.DBTableName, h.DownloadDir+"/"+filename, true)
+		var options []yuccadb.TableOption
+		if h.Header {
+			options = append(options, yuccadb.WithHeader())
+		}
+
+		if h.FieldDelimiter != 0 {
+			options = append(options, yuccadb.WithDelimiter(h.FieldDelimiter))
+		}
+
+		db.PutTable(table.DBTableName, h.DownloadDir+"/"+filename, true, options...)
 
 		h.Logger.Infof("Imported table `%s.%s.%s` to %q\n", projectID, datasetID, tableID, table.DBTableName)
 	}
@@ -0,0 +1,128 @@
+// Package bloom implements a bloom filter of string keys.
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+type Filter struct {
+	bits []uint64
+	m    uint64 // number of bits
+	k    uint64 // number of hash functions
+}
+
+// New returns a filter sized for n keys with the false positive rate fpRate.
+func New(n int64, fpRate float64) *Filter {
+	if n < 1 {
+		n = 1
+	}
+
+	// m = -n ln(p) / (ln 2)^2, k = m/n ln 2
+	m := uint64(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &Filter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// Hash returns the hash of key used by AddHash and TestHash.
+func Hash(key string) uint64 {
+	// FNV-1a, inlined to avoid allocations of hash/fnv
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+
+	hash := uint64(offset64)
+	for i := range len(key) {
+		hash ^= uint64(key[i])
+		hash *= prime64
+	}
+
+	return hash
+}
+
+func (f *Filter) Add(key string) {
+	f.AddHash(Hash(key))
+}
+
+func (f *Filter) AddHash(hash uint64) {
+	h1, h2 := hash&math.MaxUint32, hash>>32|1
+
+	for i := range f.k {
+		bit := (h1 + i*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Test reports whether key may be in the filter. False means key is definitely not added.
+func (f *Filter) Test(key string) bool {
+	return f.TestHash(Hash(key))
+}
+
+func (f *Filter) TestHash(hash uint64) bool {
+	h1, h2 := hash&math.MaxUint32, hash>>32|1
+
+	for i := range f.k {
+		bit := (h1 + i*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SizeBytes returns the memory used by the bits.
+func (f *Filter) SizeBytes() int {
+	return len(f.bits) * 8
+}
+
+func (f *Filter) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, 16+len(f.bits)*8)
+	buf = binary.LittleEndian.AppendUint64(buf, f.m)
+	buf = binary.LittleEndian.AppendUint64(buf, f.k)
+
+	for _, word := range f.bits {
+		buf = binary.LittleEndian.AppendUint64(buf, word)
+	}
+
+	return buf, nil
+}
+
+var errInvalidData = errors.New("invalid bloom filter data")
+
+func (f *Filter) UnmarshalBinary(data []byte) error {
+	if len(data) < 16 {
+		return errInvalidData
+	}
+
+	m := binary.LittleEndian.Uint64(data)
+	k := binary.LittleEndian.Uint64(data[8:])
+	data = data[16:]
+
+	if m == 0 || k == 0 || uint64(len(data)) != (m+63)/64*8 {
+		return errInvalidData
+	}
+
+	bits := make([]uint64, len(data)/8)
+	for i := range bits {
+		bits[i] = binary.LittleEndian.Uint64(data[i*8:])
+	}
+
+	f.bits, f.m, f.k = bits, m, k
+
+	return nil
+}
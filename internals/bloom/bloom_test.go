@@ -0,0 +1,61 @@
+package bloom_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/yokomotod/yuccadb/internals/bloom"
+)
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	const (
+		n      = 10_000
+		fpRate = 0.01
+	)
+
+	filter := bloom.New(n, fpRate)
+	for i := range n {
+		filter.Add(strconv.Itoa(i))
+	}
+
+	data, err := filter.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := &bloom.Filter{}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []*bloom.Filter{filter, decoded} {
+		for i := range n {
+			if !f.Test(strconv.Itoa(i)) {
+				t.Fatalf("false negative for %d", i)
+			}
+		}
+
+		falsePositives := 0
+
+		for i := n; i < 2*n; i++ {
+			if f.Test(strconv.Itoa(i)) {
+				falsePositives++
+			}
+		}
+
+		if rate := float64(falsePositives) / n; rate > fpRate*2 {
+			t.Fatalf("false positive rate %v is too high", rate)
+		}
+	}
+}
+
+func TestUnmarshalBinaryError(t *testing.T) {
+	t.Parallel()
+
+	filter := &bloom.Filter{}
+	if err := filter.UnmarshalBinary([]byte{1, 2, 3}); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,96 @@
+package parquettest
+
+import "encoding/binary"
+
+// compact protocol types
+const (
+	typeStop   = 0
+	typeTrue   = 1
+	typeFalse  = 2
+	typeI32    = 5
+	typeI64    = 6
+	typeBinary = 8
+	typeList   = 9
+	typeStruct = 12
+)
+
+// thriftWriter encodes the compact protocol.
+type thriftWriter struct {
+	buf   []byte
+	last  int16
+	outer []int16
+}
+
+func (w *thriftWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - w.last; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|typ) //nolint:gosec
+	} else {
+		w.buf = append(w.buf, typ)
+		w.varint(int64(id))
+	}
+
+	w.last = id
+}
+
+func (w *thriftWriter) varint(v int64) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(v<<1^v>>63)) //nolint:gosec
+}
+
+func (w *thriftWriter) binary(v []byte) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *thriftWriter) i32Field(id int16, v int32) {
+	w.fieldHeader(id, typeI32)
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) i64Field(id int16, v int64) {
+	w.fieldHeader(id, typeI64)
+	w.varint(v)
+}
+
+func (w *thriftWriter) stringField(id int16, v string) {
+	w.fieldHeader(id, typeBinary)
+	w.binary([]byte(v))
+}
+
+func (w *thriftWriter) boolField(id int16, v bool) {
+	typ := byte(typeFalse)
+	if v {
+		typ = typeTrue
+	}
+
+	w.fieldHeader(id, typ)
+}
+
+// structField writes a struct field whose fields are written by fields.
+func (w *thriftWriter) structField(id int16, fields func()) {
+	w.fieldHeader(id, typeStruct)
+	w.writeStruct(fields)
+}
+
+// writeStruct writes a struct, e.g. an element of a list, whose fields are written by fields.
+func (w *thriftWriter) writeStruct(fields func()) {
+	w.outer = append(w.outer, w.last)
+	w.last = 0
+
+	fields()
+
+	w.buf = append(w.buf, typeStop)
+	w.last = w.outer[len(w.outer)-1]
+	w.outer = w.outer[:len(w.outer)-1]
+}
+
+// listField writes a list field header, which must be followed by n elements of the type.
+func (w *thriftWriter) listField(id int16, typ byte, n int) {
+	w.fieldHeader(id, typeList)
+
+	if n < 15 { //nolint:mnd
+		w.buf = append(w.buf, byte(n)<<4|typ) //nolint:gosec
+	} else {
+		w.buf = append(w.buf, 0xf0|typ)
+		w.buf = binary.AppendUvarint(w.buf, uint64(n))
+	}
+}
@@ -0,0 +1,621 @@
+// Package parquettest writes flat Parquet files for tests of package parquet and its users.
+package parquettest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/yokomotod/yuccadb/parquet"
+)
+
+const (
+	magic               = "PAR1"
+	defaultRowGroupRows = 100_000
+)
+
+// page types
+const (
+	pageData       = 0
+	pageDictionary = 2
+	pageDataV2     = 3
+)
+
+// encodings
+const (
+	encodingPlain         = 0
+	encodingRLE           = 3
+	encodingRLEDictionary = 8
+)
+
+const (
+	repetitionOptional = 1
+
+	noConvertedType = -1
+)
+
+// converted types
+const (
+	convertedUTF8            = 0
+	convertedDate            = 6
+	convertedTimestampMillis = 9
+	convertedTimestampMicros = 10
+	convertedJSON            = 19
+)
+
+type WriterOptions struct {
+	// RowGroupRows is the max number of rows in a row group. Default is 100,000.
+	RowGroupRows int
+	// Compression is parquet.Uncompressed or parquet.Gzip.
+	Compression parquet.Codec
+	// Dictionary encodes parquet.ByteArray columns with dictionaries.
+	Dictionary bool
+	// PageV2 writes data pages in the version 2 layout.
+	PageV2 bool
+}
+
+// Writer writes a flat Parquet file with a page per column chunk.
+// It writes BOOLEAN, INT32, INT64, FLOAT, DOUBLE and BYTE_ARRAY columns
+// of parquet.KindPlain, parquet.KindString, parquet.KindJSON, parquet.KindDate (INT32) and parquet.KindTimestamp (INT64, milli or microseconds).
+// Close must be called to write the footer.
+type Writer struct {
+	w       *bufio.Writer
+	columns []parquet.Column
+	opts    WriterOptions
+
+	offset    int64
+	rows      [][]any
+	rowGroups []writtenRowGroup
+	numRows   int64
+}
+
+type writtenRowGroup struct {
+	chunks  []writtenChunk
+	numRows int64
+	size    int64
+}
+
+type writtenChunk struct {
+	encodings            []int32
+	size                 int64
+	uncompressedSize     int64
+	dataPageOffset       int64
+	dictionaryPageOffset int64
+}
+
+func NewWriter(w io.Writer, columns []parquet.Column, opts WriterOptions) (*Writer, error) {
+	if opts.RowGroupRows <= 0 {
+		opts.RowGroupRows = defaultRowGroupRows
+	}
+
+	if opts.Compression != parquet.Uncompressed && opts.Compression != parquet.Gzip {
+		return nil, fmt.Errorf("%w: writing codec %d", parquet.ErrUnsupported, opts.Compression)
+	}
+
+	for _, c := range columns {
+		if !writable(c) {
+			return nil, fmt.Errorf("%w: writing column %q of %v and kind %d", parquet.ErrUnsupported, c.Name, c.Type, c.Kind)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(magic); err != nil {
+		return nil, fmt.Errorf("WriteString: %w", err)
+	}
+
+	return &Writer{
+		w:       bw,
+		columns: columns,
+		opts:    opts,
+		offset:  int64(len(magic)),
+	}, nil
+}
+
+func writable(c parquet.Column) bool {
+	switch c.Kind { //nolint:exhaustive
+	case parquet.KindPlain:
+		return c.Type != parquet.Int96 && c.Type != parquet.FixedLenByteArray
+	case parquet.KindString, parquet.KindJSON:
+		return c.Type == parquet.ByteArray
+	case parquet.KindDate:
+		return c.Type == parquet.Int32
+	case parquet.KindTimestamp:
+		return c.Type == parquet.Int64 && (c.Unit == time.Millisecond || c.Unit == time.Microsecond)
+	default:
+		return false
+	}
+}
+
+// Write adds a row of values in column order.
+// Values are bool, int32, int64, float32, float64, string or []byte by the column type,
+// time.Time for parquet.KindDate and parquet.KindTimestamp, or nil for nulls of optional columns.
+func (w *Writer) Write(row []any) error {
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("%d values, but %d columns", len(row), len(w.columns))
+	}
+
+	for i, v := range row {
+		if v == nil && !w.columns[i].Optional {
+			return fmt.Errorf("null value for required column %q", w.columns[i].Name)
+		}
+
+		if v != nil {
+			if _, err := appendPlain(nil, &w.columns[i], v); err != nil {
+				return fmt.Errorf("column %q: %w", w.columns[i].Name, err)
+			}
+		}
+	}
+
+	w.rows = append(w.rows, row)
+
+	if len(w.rows) == w.opts.RowGroupRows {
+		return w.flush()
+	}
+
+	return nil
+}
+
+// flush writes the buffered rows as a row group.
+func (w *Writer) flush() error {
+	if len(w.rows) == 0 {
+		return nil
+	}
+
+	rg := writtenRowGroup{numRows: int64(len(w.rows))}
+
+	for i := range w.columns {
+		chunk, err := w.writeColumn(i)
+		if err != nil {
+			return err
+		}
+
+		rg.chunks = append(rg.chunks, chunk)
+		rg.size += chunk.uncompressedSize
+	}
+
+	w.rowGroups = append(w.rowGroups, rg)
+	w.numRows += rg.numRows
+	w.rows = w.rows[:0]
+
+	return nil
+}
+
+func (w *Writer) writeColumn(i int) (writtenChunk, error) {
+	c := &w.columns[i]
+
+	var (
+		defs   []uint32
+		values []any
+	)
+
+	for _, row := range w.rows {
+		if row[i] == nil {
+			defs = append(defs, 0)
+
+			continue
+		}
+
+		defs = append(defs, 1)
+		values = append(values, row[i])
+	}
+
+	chunk := writtenChunk{dataPageOffset: w.offset}
+	encoding := int32(encodingPlain)
+
+	var data []byte
+
+	if w.opts.Dictionary && c.Type == parquet.ByteArray {
+		dictionary, indices, err := buildDictionary(values)
+		if err != nil {
+			return chunk, fmt.Errorf("column %q: %w", c.Name, err)
+		}
+
+		var plain []byte
+
+		for _, v := range dictionary {
+			plain = appendByteArray(plain, v)
+		}
+
+		chunk.dictionaryPageOffset = w.offset
+
+		if err := w.writePage(&chunk, pageDictionary, plain, len(dictionary), nil, 0); err != nil {
+			return chunk, err
+		}
+
+		chunk.dataPageOffset = w.offset
+		chunk.encodings = append(chunk.encodings, encodingPlain)
+		encoding = encodingRLEDictionary
+
+		width := bitWidth(len(dictionary) - 1)
+		data = appendBitPacked([]byte{byte(width)}, indices, width)
+	} else {
+		for _, v := range values {
+			var err error
+
+			data, err = appendPlain(data, c, v)
+			if err != nil {
+				return chunk, fmt.Errorf("column %q: %w", c.Name, err)
+			}
+		}
+
+		if c.Type == parquet.Boolean {
+			data = packBools(values)
+		}
+	}
+
+	var levels []byte
+	if c.Optional {
+		levels = appendBitPacked(nil, defs, 1)
+	}
+
+	chunk.encodings = append(chunk.encodings, encoding, encodingRLE)
+
+	if err := w.writePage(&chunk, pageData, data, len(defs), levels, encoding); err != nil {
+		return chunk, err
+	}
+
+	return chunk, nil
+}
+
+func buildDictionary(values []any) ([][]byte, []uint32, error) {
+	var dictionary [][]byte
+
+	ids := make(map[string]uint32)
+	indices := make([]uint32, len(values))
+
+	for i, v := range values {
+		b, err := byteArray(v)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		id, ok := ids[string(b)]
+		if !ok {
+			id = uint32(len(dictionary)) //nolint:gosec
+			ids[string(b)] = id
+			dictionary = append(dictionary, b)
+		}
+
+		indices[i] = id
+	}
+
+	return dictionary, indices, nil
+}
+
+// writePage writes a page of values, preceded by definition levels for data pages of optional columns.
+func (w *Writer) writePage(chunk *writtenChunk, typ int32, values []byte, n int, levels []byte, encoding int32) error {
+	var uncompressed, page []byte
+
+	switch {
+	case typ == pageData && w.opts.PageV2:
+		typ = pageDataV2
+
+		compressed, err := w.compress(values)
+		if err != nil {
+			return err
+		}
+
+		// levels are neither compressed nor counted in the compressed values
+		uncompressed = append(append([]byte{}, levels...), values...)
+		page = append(append([]byte{}, levels...), compressed...)
+	case typ == pageData && levels != nil:
+		uncompressed = binary.LittleEndian.AppendUint32(nil, uint32(len(levels))) //nolint:gosec
+		uncompressed = append(uncompressed, levels...)
+		uncompressed = append(uncompressed, values...)
+	default:
+		uncompressed = values
+	}
+
+	if page == nil {
+		var err error
+
+		page, err = w.compress(uncompressed)
+		if err != nil {
+			return err
+		}
+	}
+
+	header := &thriftWriter{}
+	header.writeStruct(func() {
+		header.i32Field(1, typ)
+		header.i32Field(2, int32(len(uncompressed))) //nolint:gosec,mnd
+		header.i32Field(3, int32(len(page)))         //nolint:gosec,mnd
+
+		switch typ {
+		case pageDictionary:
+			header.structField(7, func() { //nolint:mnd
+				header.i32Field(1, int32(n))      //nolint:gosec
+				header.i32Field(2, encodingPlain) //nolint:mnd
+			})
+		case pageData:
+			header.structField(5, func() { //nolint:mnd
+				header.i32Field(1, int32(n))    //nolint:gosec
+				header.i32Field(2, encoding)    //nolint:mnd
+				header.i32Field(3, encodingRLE) //nolint:mnd
+				header.i32Field(4, encodingRLE) //nolint:mnd
+			})
+		case pageDataV2:
+			header.structField(8, func() { //nolint:mnd
+				nulls := 0
+				if levels != nil {
+					nulls = n - len(values)
+				}
+
+				header.i32Field(1, int32(n))                                    //nolint:gosec
+				header.i32Field(2, int32(nulls))                                //nolint:gosec,mnd
+				header.i32Field(3, int32(n))                                    //nolint:gosec,mnd
+				header.i32Field(4, encoding)                                    //nolint:mnd
+				header.i32Field(5, int32(len(levels)))                          //nolint:gosec,mnd
+				header.i32Field(6, 0)                                           //nolint:mnd
+				header.boolField(7, w.opts.Compression != parquet.Uncompressed) //nolint:mnd
+			})
+		}
+	})
+
+	for _, b := range [][]byte{header.buf, page} {
+		if _, err := w.w.Write(b); err != nil {
+			return fmt.Errorf("Write: %w", err)
+		}
+
+		w.offset += int64(len(b))
+	}
+
+	chunk.size += int64(len(header.buf) + len(page))
+	chunk.uncompressedSize += int64(len(header.buf) + len(uncompressed))
+
+	return nil
+}
+
+func (w *Writer) compress(data []byte) ([]byte, error) {
+	if w.opts.Compression == parquet.Uncompressed {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return nil, fmt.Errorf("gzip.Writer.Write: %w", err)
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("gzip.Writer.Close: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func appendPlain(buf []byte, c *parquet.Column, v any) ([]byte, error) {
+	switch c.Type { //nolint:exhaustive
+	case parquet.Boolean:
+		if _, ok := v.(bool); !ok {
+			return nil, fmt.Errorf("%T is not bool", v)
+		}
+
+		// packed by packBools
+		return buf, nil
+	case parquet.Int32:
+		var n int32
+
+		switch v := v.(type) {
+		case int32:
+			n = v
+		case time.Time:
+			n = int32(v.Unix() / (24 * 60 * 60)) //nolint:gosec
+		default:
+			return nil, fmt.Errorf("%T is not int32", v)
+		}
+
+		return binary.LittleEndian.AppendUint32(buf, uint32(n)), nil //nolint:gosec
+	case parquet.Int64:
+		var n int64
+
+		switch v := v.(type) {
+		case int64:
+			n = v
+		case time.Time:
+			n = v.UnixMilli()
+			if c.Unit == time.Microsecond {
+				n = v.UnixMicro()
+			}
+		default:
+			return nil, fmt.Errorf("%T is not int64", v)
+		}
+
+		return binary.LittleEndian.AppendUint64(buf, uint64(n)), nil //nolint:gosec
+	case parquet.Float:
+		f, ok := v.(float32)
+		if !ok {
+			return nil, fmt.Errorf("%T is not float32", v)
+		}
+
+		return binary.LittleEndian.AppendUint32(buf, math.Float32bits(f)), nil
+	case parquet.Double:
+		f, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("%T is not float64", v)
+		}
+
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(f)), nil
+	default:
+		b, err := byteArray(v)
+		if err != nil {
+			return nil, err
+		}
+
+		return appendByteArray(buf, b), nil
+	}
+}
+
+func byteArray(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("%T is not string or []byte", v)
+	}
+}
+
+func appendByteArray(buf, v []byte) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(v))) //nolint:gosec
+
+	return append(buf, v...)
+}
+
+func packBools(values []any) []byte {
+	packed := make([]byte, (len(values)+7)/8) //nolint:mnd
+
+	for i, v := range values {
+		if b, _ := v.(bool); b {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+
+	return packed
+}
+
+// Close writes the buffered rows and the footer, and flushes.
+// It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	meta := &thriftWriter{}
+	meta.writeStruct(func() {
+		meta.i32Field(1, 1)
+		meta.listField(2, typeStruct, len(w.columns)+1) //nolint:mnd
+		meta.writeStruct(func() {
+			meta.stringField(4, "schema")           //nolint:mnd
+			meta.i32Field(5, int32(len(w.columns))) //nolint:gosec,mnd
+		})
+
+		for _, c := range w.columns {
+			meta.writeStruct(func() { writeSchemaElement(meta, c) })
+		}
+
+		meta.i64Field(3, w.numRows)                     //nolint:mnd
+		meta.listField(4, typeStruct, len(w.rowGroups)) //nolint:mnd
+
+		for _, rg := range w.rowGroups {
+			meta.writeStruct(func() { w.writeRowGroup(meta, rg) })
+		}
+
+		meta.stringField(6, "yuccadb") //nolint:mnd
+	})
+
+	footer := binary.LittleEndian.AppendUint32(meta.buf, uint32(len(meta.buf))) //nolint:gosec
+	footer = append(footer, magic...)
+
+	if _, err := w.w.Write(footer); err != nil {
+		return fmt.Errorf("Write: %w", err)
+	}
+
+	if err := w.w.Flush(); err != nil {
+		return fmt.Errorf("Flush: %w", err)
+	}
+
+	return nil
+}
+
+func writeSchemaElement(meta *thriftWriter, c parquet.Column) {
+	repetition := int32(0)
+	if c.Optional {
+		repetition = repetitionOptional
+	}
+
+	meta.i32Field(1, int32(c.Type))
+	meta.i32Field(3, repetition) //nolint:mnd
+	meta.stringField(4, c.Name)  //nolint:mnd
+
+	converted := int32(noConvertedType)
+
+	switch c.Kind { //nolint:exhaustive
+	case parquet.KindString:
+		converted = convertedUTF8
+	case parquet.KindJSON:
+		converted = convertedJSON
+	case parquet.KindDate:
+		converted = convertedDate
+	case parquet.KindTimestamp:
+		converted = convertedTimestampMillis
+		if c.Unit == time.Microsecond {
+			converted = convertedTimestampMicros
+		}
+	}
+
+	if converted != noConvertedType {
+		meta.i32Field(6, converted) //nolint:mnd
+	}
+}
+
+func (w *Writer) writeRowGroup(meta *thriftWriter, rg writtenRowGroup) {
+	meta.listField(1, typeStruct, len(rg.chunks))
+
+	for i, chunk := range rg.chunks {
+		c := w.columns[i]
+
+		meta.writeStruct(func() {
+			meta.i64Field(2, chunk.dataPageOffset) //nolint:mnd
+			meta.structField(3, func() {           //nolint:mnd
+				meta.i32Field(1, int32(c.Type))
+				meta.listField(2, typeI32, len(chunk.encodings)) //nolint:mnd
+
+				for _, e := range chunk.encodings {
+					meta.varint(int64(e))
+				}
+
+				meta.listField(3, typeBinary, 1) //nolint:mnd
+				meta.binary([]byte(c.Name))
+				meta.i32Field(4, int32(w.opts.Compression)) //nolint:mnd
+				meta.i64Field(5, rg.numRows)                //nolint:mnd
+				meta.i64Field(6, chunk.uncompressedSize)    //nolint:mnd
+				meta.i64Field(7, chunk.size)                //nolint:mnd
+				meta.i64Field(9, chunk.dataPageOffset)      //nolint:mnd
+
+				if chunk.dictionaryPageOffset > 0 {
+					meta.i64Field(11, chunk.dictionaryPageOffset) //nolint:mnd
+				}
+			})
+		})
+	}
+
+	meta.i64Field(2, rg.size)    //nolint:mnd
+	meta.i64Field(3, rg.numRows) //nolint:mnd
+}
+
+// appendBitPacked appends values as a single bit-packed run, padded to a multiple of 8 values.
+func appendBitPacked(buf []byte, values []uint32, bitWidth int) []byte {
+	groups := (len(values) + 7) / 8 //nolint:mnd
+	buf = binary.AppendUvarint(buf, uint64(groups)<<1|1)
+
+	packed := make([]byte, groups*bitWidth)
+
+	for i, v := range values {
+		for j := range bitWidth {
+			if v>>j&1 == 1 {
+				bit := i*bitWidth + j
+				packed[bit/8] |= 1 << (bit % 8)
+			}
+		}
+	}
+
+	return append(buf, packed...)
+}
+
+// bitWidth returns the bits needed for values up to max.
+func bitWidth(max int) int {
+	width := 0
+	for ; max > 0; max >>= 1 {
+		width++
+	}
+
+	return width
+}
@@ -0,0 +1,49 @@
+package yuccadb
+
+import (
+	"errors"
+	"fmt"
+
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+// optional operations of tables beyond yuccaTable.TableReader, all implemented by *yuccaTable.Table
+type (
+	tupleGetter interface {
+		GetTuple(parts []string) (yuccaTable.Result, error)
+	}
+	multiGetter interface {
+		GetAll(key string) ([]yuccaTable.Result, error)
+	}
+	columnGetter interface {
+		GetColumns(key string, columns []string) (yuccaTable.Result, error)
+		BulkGetColumns(keys []string, columns []string) (yuccaTable.BulkResult, error)
+		ScanColumns(start, end string, limit int, columns []string) (yuccaTable.ScanResult, error)
+	}
+	reverseScanner interface {
+		ReverseScan(start, end string, limit int) (yuccaTable.ScanResult, error)
+	}
+	iterable interface {
+		Iter(start, end string) (*yuccaTable.Iterator, error)
+		PrefixScan(prefix string) (*yuccaTable.Iterator, error)
+		BulkIter(keys []string) (*yuccaTable.Iterator, error)
+	}
+	columnLister interface {
+		Columns() []string
+	}
+	schemaReader interface {
+		Schema() yuccaTable.Schema
+	}
+)
+
+var ErrUnsupportedOperation = errors.New("operation is not supported by the table format")
+
+// as returns the table as T, the interface of the operation, or ErrUnsupportedOperation.
+func as[T any](table yuccaTable.TableReader, operation string) (T, error) { //nolint:ireturn
+	t, ok := table.(T)
+	if !ok {
+		return t, fmt.Errorf("%w: %s of %s", ErrUnsupportedOperation, operation, table.Stats().Format)
+	}
+
+	return t, nil
+}
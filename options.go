@@ -0,0 +1,191 @@
+package yuccadb
+
+import (
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+// TableOption configures how PutTable builds a table.
+type TableOption func(opts *yuccaTable.BuildOptions)
+
+// WithReadMode selects how rows of the table are read on lookups.
+func WithReadMode(mode yuccaTable.ReadMode) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.ReadMode = mode
+	}
+}
+
+// WithBloomFilter builds a bloom filter of keys with the false positive rate,
+// so that lookups of missing keys mostly skip reading the file.
+func WithBloomFilter(falsePositiveRate float64) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.BloomFalsePositiveRate = falsePositiveRate
+	}
+}
+
+// WithBuildOptions replaces all build options at once.
+func WithBuildOptions(buildOptions yuccaTable.BuildOptions) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		blockCache := opts.BlockCache
+		*opts = buildOptions
+
+		if opts.BlockCache == nil {
+			opts.BlockCache = blockCache
+		}
+	}
+}
+
+// WithFormat sets the format of the data file instead of selecting it by the extension,
+// e.g. a format registered by yuccaTable.RegisterFormat.
+func WithFormat(format yuccaTable.Format) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.Format = format
+	}
+}
+
+// WithKeyField sets the field of the key in JSONL objects, or the key column of Parquet files. Default is "key".
+func WithKeyField(field string) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.KeyField = field
+	}
+}
+
+// WithIndexInterval sets the number of rows between sparse index entries.
+// Smaller intervals use more memory and make lookups faster.
+func WithIndexInterval(rows int64) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.IndexInterval = rows
+	}
+}
+
+// WithIndexBytes additionally adds an index entry once a block reaches the bytes,
+// so that lookups scan a bounded number of bytes even if row widths vary widely.
+func WithIndexBytes(bytes int64) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.IndexBytes = bytes
+	}
+}
+
+// WithDelimiter sets the field delimiter of the data file, e.g. '\t' for TSV.
+func WithDelimiter(delimiter rune) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.Delimiter = delimiter
+	}
+}
+
+// WithComment skips lines beginning with the comment character.
+func WithComment(comment rune) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.Comment = comment
+	}
+}
+
+// WithLazyQuotes allows quotes in unquoted fields and non-doubled quotes in quoted fields.
+func WithLazyQuotes() TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.LazyQuotes = true
+	}
+}
+
+// WithTrimLeadingSpace ignores leading white space of fields.
+func WithTrimLeadingSpace() TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.TrimLeadingSpace = true
+	}
+}
+
+// WithKeyColumn sets the 0-based column of the key.
+func WithKeyColumn(column int) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.KeyColumn = column
+	}
+}
+
+// WithKeyColumns makes a composite key from the columns joined with the key separator,
+// so that the table can be read with GetTuple. Rows must be sorted by the joined keys.
+func WithKeyColumns(columns ...int) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.KeyColumns = columns
+	}
+}
+
+// WithKeySeparator sets the separator of composite keys. Default is "\x00".
+func WithKeySeparator(separator string) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.KeySeparator = separator
+	}
+}
+
+// WithHeader skips the first row of the data file as a header, and takes column names from it.
+func WithHeader() TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.Header = true
+	}
+}
+
+// WithColumns names all columns including the key column in file order,
+// so that values can be accessed by names with Result.Get.
+func WithColumns(names ...string) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.Columns = names
+	}
+}
+
+// WithSchema types all columns including the key column in file order.
+// Rows are validated on load, and PutTable fails on the first invalid value.
+func WithSchema(schema yuccaTable.Schema) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.Schema = schema
+	}
+}
+
+// WithVerify sets how much an existing index file is checked before it is trusted.
+func WithVerify(level yuccaTable.VerifyLevel) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.Verify = level
+	}
+}
+
+// WithSort sorts the rows by key to yuccaTable.PreparedFile with an external merge sort when the keys of the data file
+// are not sorted, leaving the data file as is,
+// e.g. for files sorted upstream by a collation other than Go byte order.
+func WithSort() TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.Sort = true
+	}
+}
+
+// WithSortMemory sets the approximate memory used by WithSort. Default is 64 MiB.
+func WithSortMemory(bytes int64) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.SortMemory = bytes
+	}
+}
+
+// WithSortDir sets the directory of temporary files of WithSort. Default is os.TempDir().
+func WithSortDir(dir string) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.SortDir = dir
+	}
+}
+
+// WithDuplicates sets how rows with equal keys are handled. Default is yuccaTable.DuplicateKeepFirst.
+func WithDuplicates(policy yuccaTable.DuplicatePolicy) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.Duplicates = policy
+	}
+}
+
+// WithBadRows sets how rows which cannot be loaded are handled, e.g. yuccaTable.BadRowsSkip
+// to load a table from the rest of the rows, leaving the data file as is. Default is yuccaTable.BadRowsFail.
+func WithBadRows(policy yuccaTable.BadRowPolicy) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.BadRows = policy
+	}
+}
+
+// WithQuarantineFile sets the file which yuccaTable.BadRowsQuarantine appends bad rows to.
+func WithQuarantineFile(file string) TableOption {
+	return func(opts *yuccaTable.BuildOptions) {
+		opts.QuarantineFile = file
+	}
+}
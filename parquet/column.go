@@ -0,0 +1,548 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// page types
+const (
+	pageData       = 0
+	pageDictionary = 2
+	pageDataV2     = 3
+)
+
+type pageHeader struct {
+	typ              int32
+	uncompressedSize int32
+	compressedSize   int32
+
+	numValues      int32
+	encoding       int32
+	defEncoding    int32
+	numNulls       int32
+	defLength      int32
+	repLength      int32
+	uncompressedV2 bool // values of v2 pages are not compressed
+}
+
+func readPageHeader(r *thriftReader) pageHeader {
+	var h pageHeader
+
+	h.defEncoding = encodingRLE
+
+	r.readStruct(func(id int16, typ byte) {
+		switch id {
+		case 1:
+			h.typ = r.i32()
+		case 2: //nolint:mnd
+			h.uncompressedSize = r.i32()
+		case 3: //nolint:mnd
+			h.compressedSize = r.i32()
+		case 5: //nolint:mnd // DataPageHeader
+			r.readStruct(func(id int16, typ byte) {
+				switch id {
+				case 1:
+					h.numValues = r.i32()
+				case 2: //nolint:mnd
+					h.encoding = r.i32()
+				case 3: //nolint:mnd
+					h.defEncoding = r.i32()
+				default:
+					r.skip(typ)
+				}
+			})
+		case 7: //nolint:mnd // DictionaryPageHeader, whose field 3 is the is_sorted bool
+			r.readStruct(func(id int16, typ byte) {
+				switch id {
+				case 1:
+					h.numValues = r.i32()
+				case 2: //nolint:mnd
+					h.encoding = r.i32()
+				default:
+					r.skip(typ)
+				}
+			})
+		case 8: //nolint:mnd // DataPageHeaderV2
+			r.readStruct(func(id int16, typ byte) {
+				switch id {
+				case 1:
+					h.numValues = r.i32()
+				case 2: //nolint:mnd
+					h.numNulls = r.i32()
+				case 4: //nolint:mnd
+					h.encoding = r.i32()
+				case 5: //nolint:mnd
+					h.defLength = r.i32()
+				case 6: //nolint:mnd
+					h.repLength = r.i32()
+				case 7: //nolint:mnd
+					h.uncompressedV2 = !r.bool(typ)
+				default:
+					r.skip(typ)
+				}
+			})
+		default:
+			r.skip(typ)
+		}
+	})
+
+	return h
+}
+
+// readColumn returns the values of the column chunk, with empty strings for nulls.
+func (f *File) readColumn(c *Column, chunk columnChunk, numRows int64) ([]string, error) {
+	start := chunk.dataPageOffset
+	if chunk.dictionaryPageOffset > 0 && chunk.dictionaryPageOffset < start {
+		start = chunk.dictionaryPageOffset
+	}
+
+	if start < 0 || chunk.totalCompressedSize < 0 || start+chunk.totalCompressedSize > f.size {
+		return nil, fmt.Errorf("%w: bad column chunk location", ErrCorrupted)
+	}
+
+	buf := make([]byte, chunk.totalCompressedSize)
+	if _, err := f.r.ReadAt(buf, start); err != nil {
+		return nil, fmt.Errorf("ReadAt: %w", err)
+	}
+
+	var (
+		values     []string
+		dictionary []string
+	)
+
+	for int64(len(values)) < numRows {
+		if len(buf) == 0 {
+			return nil, fmt.Errorf("%w: %d values, want %d", ErrCorrupted, len(values), numRows)
+		}
+
+		r := &thriftReader{buf: buf}
+
+		h := readPageHeader(r)
+		if r.err != nil {
+			return nil, r.err
+		}
+
+		buf = r.buf
+
+		if h.compressedSize < 0 || int(h.compressedSize) > len(buf) || h.uncompressedSize < 0 || h.numValues < 0 {
+			return nil, fmt.Errorf("%w: bad page size", ErrCorrupted)
+		}
+
+		if h.typ != pageDictionary && int64(len(values))+int64(h.numValues) > numRows {
+			return nil, fmt.Errorf("%w: more values than rows", ErrCorrupted)
+		}
+
+		page := buf[:h.compressedSize]
+		buf = buf[h.compressedSize:]
+
+		var err error
+
+		switch h.typ {
+		case pageDictionary:
+			var data []byte
+
+			data, err = decompress(chunk.codec, page, int(h.uncompressedSize))
+			if err == nil {
+				dictionary, err = c.decodePlain(data, int(h.numValues))
+			}
+		case pageData:
+			var data []byte
+
+			data, err = decompress(chunk.codec, page, int(h.uncompressedSize))
+			if err == nil {
+				values, err = c.readDataPage(data, h, dictionary, values)
+			}
+		case pageDataV2:
+			values, err = c.readDataPageV2(page, h, chunk.codec, dictionary, values)
+		default:
+			// index pages and so on
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if int64(len(values)) != numRows {
+		return nil, fmt.Errorf("%w: %d values, want %d", ErrCorrupted, len(values), numRows)
+	}
+
+	return values, nil
+}
+
+// readDataPage reads a v1 page, whose definition levels are prefixed by their length.
+func (c *Column) readDataPage(data []byte, h pageHeader, dictionary, values []string) ([]string, error) {
+	var defs []uint32
+
+	if c.Optional {
+		if h.defEncoding != encodingRLE {
+			return nil, fmt.Errorf("%w: definition level encoding %d", ErrUnsupported, h.defEncoding)
+		}
+
+		if len(data) < 4 { //nolint:mnd
+			return nil, fmt.Errorf("%w: no definition levels", ErrCorrupted)
+		}
+
+		length := binary.LittleEndian.Uint32(data)
+		if uint64(length) > uint64(len(data)-4) {
+			return nil, fmt.Errorf("%w: bad definition levels length", ErrCorrupted)
+		}
+
+		var err error
+
+		defs, err = decodeRLE(data[4:4+length], 1, int(h.numValues))
+		if err != nil {
+			return nil, err
+		}
+
+		data = data[4+length:]
+	}
+
+	return c.appendValues(values, defs, int(h.numValues), h.encoding, data, dictionary)
+}
+
+// readDataPageV2 reads a v2 page, whose levels are not compressed and not prefixed by their length.
+func (c *Column) readDataPageV2(page []byte, h pageHeader, codec Codec, dictionary, values []string) ([]string, error) {
+	if h.repLength < 0 || h.defLength < 0 || int64(h.repLength)+int64(h.defLength) > int64(len(page)) {
+		return nil, fmt.Errorf("%w: bad levels length", ErrCorrupted)
+	}
+
+	if h.repLength > 0 {
+		return nil, fmt.Errorf("%w: repetition levels", ErrUnsupported)
+	}
+
+	var defs []uint32
+
+	if c.Optional {
+		var err error
+
+		defs, err = decodeRLE(page[:h.defLength], 1, int(h.numValues))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data := page[h.defLength:]
+
+	if !h.uncompressedV2 {
+		var err error
+
+		data, err = decompress(codec, data, int(h.uncompressedSize-h.defLength))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c.appendValues(values, defs, int(h.numValues), h.encoding, data, dictionary)
+}
+
+// appendValues decodes non-null values and appends n values with nulls where defs are 0.
+func (c *Column) appendValues(values []string, defs []uint32, n int, encoding int32, data []byte, dictionary []string) ([]string, error) {
+	nonNull := n
+
+	if defs != nil {
+		nonNull = 0
+
+		for _, d := range defs {
+			if d > 0 {
+				nonNull++
+			}
+		}
+	}
+
+	decoded, err := c.decodeValues(encoding, data, nonNull, dictionary)
+	if err != nil {
+		return nil, err
+	}
+
+	if defs == nil {
+		return append(values, decoded...), nil
+	}
+
+	for _, d := range defs {
+		if d > 0 {
+			values = append(values, decoded[0])
+			decoded = decoded[1:]
+		} else {
+			values = append(values, "")
+		}
+	}
+
+	return values, nil
+}
+
+func (c *Column) decodeValues(encoding int32, data []byte, n int, dictionary []string) ([]string, error) {
+	switch encoding {
+	case encodingPlain:
+		return c.decodePlain(data, n)
+	case encodingPlainDictionary, encodingRLEDictionary:
+		if dictionary == nil {
+			return nil, fmt.Errorf("%w: no dictionary page", ErrCorrupted)
+		}
+
+		if len(data) == 0 {
+			if n == 0 {
+				return nil, nil
+			}
+
+			return nil, fmt.Errorf("%w: no dictionary indices", ErrCorrupted)
+		}
+
+		indices, err := decodeRLE(data[1:], int(data[0]), n)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make([]string, n)
+
+		for i, index := range indices {
+			if int(index) >= len(dictionary) {
+				return nil, fmt.Errorf("%w: dictionary index %d out of %d", ErrCorrupted, index, len(dictionary))
+			}
+
+			values[i] = dictionary[index]
+		}
+
+		return values, nil
+	case encodingRLE:
+		if c.Type != Boolean || len(data) < 4 { //nolint:mnd
+			return nil, fmt.Errorf("%w: RLE encoding of %v", ErrUnsupported, c.Type)
+		}
+
+		bits, err := decodeRLE(data[4:], 1, n)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make([]string, n)
+		for i, b := range bits {
+			values[i] = strconv.FormatBool(b == 1)
+		}
+
+		return values, nil
+	default:
+		return nil, fmt.Errorf("%w: encoding %d", ErrUnsupported, encoding)
+	}
+}
+
+// decodePlain decodes n PLAIN encoded values.
+func (c *Column) decodePlain(data []byte, n int) ([]string, error) {
+	size := 0
+
+	switch c.Type {
+	case Boolean:
+		if len(data)*8 < n {
+			return nil, fmt.Errorf("%w: too few values", ErrCorrupted)
+		}
+
+		values := make([]string, n)
+		for i := range values {
+			values[i] = strconv.FormatBool(data[i/8]>>(i%8)&1 == 1)
+		}
+
+		return values, nil
+	case Int32, Float:
+		size = 4
+	case Int64, Double:
+		size = 8
+	case Int96:
+		size = 12
+	case FixedLenByteArray:
+		size = int(c.Length)
+		if size <= 0 {
+			return nil, fmt.Errorf("%w: bad fixed length %d", ErrCorrupted, c.Length)
+		}
+	case ByteArray:
+		// length-prefixed
+		if len(data)/4 < n {
+			return nil, fmt.Errorf("%w: too few values", ErrCorrupted)
+		}
+	}
+
+	if size > 0 && len(data) < n*size {
+		return nil, fmt.Errorf("%w: too few values", ErrCorrupted)
+	}
+
+	values := make([]string, n)
+
+	for i := range values {
+		var v []byte
+
+		if size > 0 {
+			v = data[:size]
+			data = data[size:]
+		} else {
+			if len(data) < 4 { //nolint:mnd
+				return nil, fmt.Errorf("%w: too few values", ErrCorrupted)
+			}
+
+			length := binary.LittleEndian.Uint32(data)
+			if uint64(length) > uint64(len(data)-4) {
+				return nil, fmt.Errorf("%w: bad byte array length", ErrCorrupted)
+			}
+
+			v = data[4 : 4+length]
+			data = data[4+length:]
+		}
+
+		values[i] = c.format(v)
+	}
+
+	return values, nil
+}
+
+// julianUnixEpoch is the Julian day of 1970-01-01, for INT96 timestamps.
+const julianUnixEpoch = 2_440_588
+
+// format formats a PLAIN encoded value by the kind of the column.
+func (c *Column) format(v []byte) string {
+	switch c.Type {
+	case Int32:
+		n := int32(binary.LittleEndian.Uint32(v)) //nolint:gosec
+
+		switch c.Kind { //nolint:exhaustive
+		case KindDate:
+			return time.Unix(int64(n)*24*60*60, 0).UTC().Format(time.DateOnly)
+		case KindTime:
+			return formatTime(int64(n), c.unit())
+		case KindDecimal:
+			return formatDecimal(big.NewInt(int64(n)), c.Scale)
+		case KindUnsigned:
+			return strconv.FormatUint(uint64(uint32(n)), 10) //nolint:gosec
+		}
+
+		return strconv.FormatInt(int64(n), 10)
+	case Int64:
+		n := int64(binary.LittleEndian.Uint64(v)) //nolint:gosec
+
+		switch c.Kind { //nolint:exhaustive
+		case KindTimestamp:
+			return formatTimestamp(n, c.unit())
+		case KindTime:
+			return formatTime(n, c.unit())
+		case KindDecimal:
+			return formatDecimal(big.NewInt(n), c.Scale)
+		case KindUnsigned:
+			return strconv.FormatUint(uint64(n), 10) //nolint:gosec
+		}
+
+		return strconv.FormatInt(n, 10)
+	case Int96:
+		nanos := int64(binary.LittleEndian.Uint64(v)) //nolint:gosec
+		days := int64(binary.LittleEndian.Uint32(v[8:]))
+
+		return time.Unix((days-julianUnixEpoch)*24*60*60, nanos).UTC().Format(time.RFC3339Nano)
+	case Float:
+		return strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(v))), 'g', -1, 32)
+	case Double:
+		return strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(v)), 'g', -1, 64)
+	case ByteArray, FixedLenByteArray:
+		switch c.Kind { //nolint:exhaustive
+		case KindString, KindJSON:
+			return string(v)
+		case KindDecimal:
+			return formatDecimal(twosComplement(v), c.Scale)
+		}
+
+		return base64.StdEncoding.EncodeToString(v)
+	default:
+		return ""
+	}
+}
+
+func (c *Column) unit() time.Duration {
+	if c.Unit == 0 {
+		return time.Millisecond
+	}
+
+	return c.Unit
+}
+
+func formatTimestamp(n int64, unit time.Duration) string {
+	perSecond := int64(time.Second / unit)
+
+	return time.Unix(n/perSecond, n%perSecond*int64(unit)).UTC().Format(time.RFC3339Nano)
+}
+
+func formatTime(n int64, unit time.Duration) string {
+	return time.Unix(0, n*int64(unit)).UTC().Format("15:04:05.999999999")
+}
+
+// twosComplement decodes a big-endian two's complement integer.
+func twosComplement(v []byte) *big.Int {
+	n := new(big.Int).SetBytes(v)
+	if len(v) > 0 && v[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(v))*8)) //nolint:mnd
+	}
+
+	return n
+}
+
+func formatDecimal(unscaled *big.Int, scale int32) string {
+	if scale <= 0 {
+		return unscaled.String()
+	}
+
+	digits := new(big.Int).Abs(unscaled).String()
+	if len(digits) <= int(scale) {
+		digits = string(bytes.Repeat([]byte{'0'}, int(scale)-len(digits)+1)) + digits
+	}
+
+	s := digits[:len(digits)-int(scale)] + "." + digits[len(digits)-int(scale):]
+	if unscaled.Sign() < 0 {
+		s = "-" + s
+	}
+
+	return s
+}
+
+// maxPageSize bounds allocations for corrupted page sizes.
+const maxPageSize = 1 << 30
+
+func decompress(codec Codec, src []byte, size int) ([]byte, error) {
+	if size < 0 || size > maxPageSize {
+		return nil, fmt.Errorf("%w: bad uncompressed page size %d", ErrCorrupted, size)
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+
+	switch codec {
+	case Uncompressed:
+		return src, nil
+	case Snappy:
+		data, err = snappyDecode(src)
+	case Gzip:
+		var gz *gzip.Reader
+
+		gz, err = gzip.NewReader(bytes.NewReader(src))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrCorrupted, err)
+		}
+
+		data, err = io.ReadAll(io.LimitReader(gz, int64(size)+1))
+	default:
+		return nil, fmt.Errorf("%w: codec %d", ErrUnsupported, codec)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorrupted, err)
+	}
+
+	if len(data) != size {
+		return nil, fmt.Errorf("%w: uncompressed page size %d, want %d", ErrCorrupted, len(data), size)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,170 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// decodeRLE decodes n values of the RLE / bit-packing hybrid encoding,
+// used for definition levels, dictionary indices and booleans.
+func decodeRLE(data []byte, bitWidth, n int) ([]uint32, error) {
+	if bitWidth > 32 { //nolint:mnd
+		return nil, fmt.Errorf("%w: bit width %d", ErrCorrupted, bitWidth)
+	}
+
+	values := make([]uint32, 0, min(n, len(data)*8+8)) //nolint:mnd
+
+	for len(values) < n {
+		header, k := binary.Uvarint(data)
+		if k <= 0 {
+			return nil, fmt.Errorf("%w: bad RLE header", ErrCorrupted)
+		}
+
+		data = data[k:]
+
+		count := int(min(header>>1, uint64(n))) //nolint:gosec
+		if count == 0 {
+			return nil, fmt.Errorf("%w: empty RLE run", ErrCorrupted)
+		}
+
+		if header&1 == 0 {
+			// run of a repeated value in the least bytes of its width
+			width := (bitWidth + 7) / 8 //nolint:mnd
+			if len(data) < width {
+				return nil, fmt.Errorf("%w: short RLE run", ErrCorrupted)
+			}
+
+			var v uint32
+			for i := range width {
+				v |= uint32(data[i]) << (8 * i)
+			}
+
+			data = data[width:]
+
+			for i := 0; i < count && len(values) < n; i++ {
+				values = append(values, v)
+			}
+
+			continue
+		}
+
+		// groups of 8 values packed from the least significant bit
+		groups := count
+		size := min(groups*bitWidth, len(data))
+
+		for i := 0; i < groups*8 && len(values) < n; i++ {
+			if (i+1)*bitWidth > size*8 {
+				return nil, fmt.Errorf("%w: short bit-packed run", ErrCorrupted)
+			}
+
+			values = append(values, readBits(data, i*bitWidth, bitWidth))
+		}
+
+		data = data[size:]
+	}
+
+	return values, nil
+}
+
+func readBits(data []byte, offset, width int) uint32 {
+	var v uint32
+
+	for i := range width {
+		bit := offset + i
+		if data[bit/8]>>(bit%8)&1 == 1 {
+			v |= 1 << i
+		}
+	}
+
+	return v
+}
+
+var errSnappy = errors.New("bad snappy data")
+
+// snappyDecode decodes a snappy block, which Parquet uses without the framing format.
+func snappyDecode(src []byte) ([]byte, error) {
+	length, k := binary.Uvarint(src)
+	if k <= 0 || length > maxPageSize {
+		return nil, errSnappy
+	}
+
+	src = src[k:]
+	dst := make([]byte, 0, length)
+
+	for len(src) > 0 {
+		tag := src[0]
+
+		var n, offset int
+
+		switch tag & 3 { //nolint:mnd
+		case 0: // literal
+			n = int(tag>>2) + 1
+			src = src[1:]
+
+			if n > 60 { //nolint:mnd
+				// the length - 1 follows in n - 60 bytes
+				size := n - 60 //nolint:mnd
+				if len(src) < size {
+					return nil, errSnappy
+				}
+
+				n = 0
+				for i := range size {
+					n |= int(src[i]) << (8 * i)
+				}
+
+				n++
+				src = src[size:]
+			}
+
+			if n > len(src) {
+				return nil, errSnappy
+			}
+
+			dst = append(dst, src[:n]...)
+			src = src[n:]
+
+			continue
+		case 1: // copy with a 1-byte offset
+			if len(src) < 2 { //nolint:mnd
+				return nil, errSnappy
+			}
+
+			n = int(tag>>2&7) + 4                   //nolint:mnd
+			offset = int(tag&0xe0)<<3 | int(src[1]) //nolint:mnd
+			src = src[2:]
+		case 2: // copy with a 2-byte offset
+			if len(src) < 3 { //nolint:mnd
+				return nil, errSnappy
+			}
+
+			n = int(tag>>2) + 1
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case 3: // copy with a 4-byte offset
+			if len(src) < 5 { //nolint:mnd
+				return nil, errSnappy
+			}
+
+			n = int(tag>>2) + 1
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+
+		if offset <= 0 || offset > len(dst) {
+			return nil, errSnappy
+		}
+
+		// copies may overlap their output, e.g. to repeat a byte
+		for range n {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+
+	if uint64(len(dst)) != length {
+		return nil, errSnappy
+	}
+
+	return dst, nil
+}
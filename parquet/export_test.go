@@ -0,0 +1,3 @@
+package parquet
+
+var SnappyDecode = snappyDecode //nolint:gochecknoglobals
@@ -0,0 +1,590 @@
+// Package parquet reads flat Parquet files with the standard library only.
+//
+// It supports what BigQuery, Spark and Arrow write for tables of scalar columns:
+// required and optional columns of all physical types, PLAIN and dictionary encodings,
+// data pages v1 and v2, and UNCOMPRESSED, SNAPPY and GZIP compression.
+// Nested and repeated columns, other encodings and other codecs are rejected with ErrUnsupported.
+//
+// Values are read as strings formatted by their logical types, and nulls are empty strings.
+// Row groups are decoded at once, so memory use is proportional to the row group size.
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	magic      = "PAR1"
+	footerSize = 4 + len(magic)
+)
+
+var (
+	ErrCorrupted   = errors.New("parquet file is corrupted")
+	ErrUnsupported = errors.New("unsupported parquet feature")
+)
+
+// Type is the physical type of a column.
+type Type int32
+
+const (
+	Boolean Type = iota
+	Int32
+	Int64
+	Int96
+	Float
+	Double
+	ByteArray
+	FixedLenByteArray
+)
+
+func (t Type) String() string {
+	switch t {
+	case Boolean:
+		return "BOOLEAN"
+	case Int32:
+		return "INT32"
+	case Int64:
+		return "INT64"
+	case Int96:
+		return "INT96"
+	case Float:
+		return "FLOAT"
+	case Double:
+		return "DOUBLE"
+	case ByteArray:
+		return "BYTE_ARRAY"
+	case FixedLenByteArray:
+		return "FIXED_LEN_BYTE_ARRAY"
+	default:
+		return fmt.Sprintf("Type(%d)", int32(t))
+	}
+}
+
+// Kind is the logical type of a column, which selects how values are formatted.
+type Kind int
+
+const (
+	// KindPlain formats values by the physical type, and byte arrays as base64.
+	KindPlain Kind = iota
+	KindString
+	KindJSON
+	// KindDate formats days since the epoch as "2006-01-02".
+	KindDate
+	// KindTime formats times of day as "15:04:05.999999999".
+	KindTime
+	// KindTimestamp formats instants in RFC 3339 in UTC.
+	KindTimestamp
+	// KindDecimal formats unscaled integers with Scale digits after the decimal point.
+	KindDecimal
+	// KindUnsigned formats integers as unsigned.
+	KindUnsigned
+)
+
+// Codec is the compression codec of column chunks.
+type Codec int32
+
+const (
+	Uncompressed Codec = 0
+	Snappy       Codec = 1
+	Gzip         Codec = 2
+)
+
+// encodings
+const (
+	encodingPlain           = 0
+	encodingPlainDictionary = 2
+	encodingRLE             = 3
+	encodingRLEDictionary   = 8
+)
+
+// Column describes a column of a flat schema.
+type Column struct {
+	Name     string
+	Type     Type
+	Kind     Kind
+	Optional bool
+	// Length is the byte length of FixedLenByteArray values.
+	Length int32
+	// Scale is the number of digits after the decimal point of KindDecimal values.
+	Scale int32
+	// Unit is the unit of KindTime and KindTimestamp values.
+	Unit time.Duration
+}
+
+type columnChunk struct {
+	codec                Codec
+	numValues            int64
+	totalCompressedSize  int64
+	dataPageOffset       int64
+	dictionaryPageOffset int64
+}
+
+type rowGroup struct {
+	columns []columnChunk
+	numRows int64
+}
+
+// File is an open Parquet file.
+type File struct {
+	r         io.ReaderAt
+	size      int64
+	columns   []Column
+	rowGroups []rowGroup
+	numRows   int64
+}
+
+// Open reads the metadata from the footer of the file of size.
+func Open(r io.ReaderAt, size int64) (*File, error) {
+	if size < int64(len(magic)+footerSize) {
+		return nil, fmt.Errorf("%w: file is too small", ErrCorrupted)
+	}
+
+	header := make([]byte, len(magic))
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("ReadAt: %w", err)
+	}
+
+	footer := make([]byte, footerSize)
+	if _, err := r.ReadAt(footer, size-int64(footerSize)); err != nil {
+		return nil, fmt.Errorf("ReadAt: %w", err)
+	}
+
+	if string(header) != magic || string(footer[4:]) != magic {
+		if string(footer[4:]) == "PARE" {
+			return nil, fmt.Errorf("%w: encrypted footer", ErrUnsupported)
+		}
+
+		return nil, fmt.Errorf("%w: bad magic", ErrCorrupted)
+	}
+
+	metaSize := int64(binary.LittleEndian.Uint32(footer))
+	if metaSize > size-int64(len(magic)+footerSize) {
+		return nil, fmt.Errorf("%w: bad metadata size", ErrCorrupted)
+	}
+
+	meta := make([]byte, metaSize)
+	if _, err := r.ReadAt(meta, size-int64(footerSize)-metaSize); err != nil {
+		return nil, fmt.Errorf("ReadAt: %w", err)
+	}
+
+	f := &File{r: r, size: size}
+	if err := f.readMetadata(meta); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Columns returns the columns in file order.
+func (f *File) Columns() []Column {
+	return f.columns
+}
+
+func (f *File) NumRows() int64 {
+	return f.numRows
+}
+
+func (f *File) NumRowGroups() int {
+	return len(f.rowGroups)
+}
+
+type schemaElement struct {
+	typ           Type
+	hasType       bool
+	typeLength    int32
+	repetition    int32
+	name          string
+	numChildren   int32
+	convertedType int32
+	scale         int32
+	logical       logicalType
+}
+
+type logicalType struct {
+	kind     Kind
+	unit     time.Duration
+	scale    int32
+	unsigned bool
+	ok       bool
+}
+
+const (
+	repetitionOptional = 1
+	repetitionRepeated = 2
+
+	noConvertedType = -1
+)
+
+// readMetadata decodes FileMetaData.
+func (f *File) readMetadata(buf []byte) error {
+	r := &thriftReader{buf: buf}
+
+	var schema []schemaElement
+
+	r.readStruct(func(id int16, typ byte) {
+		switch id {
+		case 2: //nolint:mnd
+			r.readList(func(byte) {
+				schema = append(schema, readSchemaElement(r))
+			})
+		case 3: //nolint:mnd
+			f.numRows = r.varint()
+		case 4: //nolint:mnd
+			r.readList(func(byte) {
+				f.rowGroups = append(f.rowGroups, readRowGroup(r))
+			})
+		default:
+			r.skip(typ)
+		}
+	})
+
+	if r.err != nil {
+		return r.err
+	}
+
+	columns, err := flatColumns(schema)
+	if err != nil {
+		return err
+	}
+
+	for i, rg := range f.rowGroups {
+		if len(rg.columns) != len(columns) {
+			return fmt.Errorf("%w: row group %d has %d columns, but schema has %d",
+				ErrCorrupted, i, len(rg.columns), len(columns))
+		}
+	}
+
+	f.columns = columns
+
+	return nil
+}
+
+func readSchemaElement(r *thriftReader) schemaElement {
+	e := schemaElement{convertedType: noConvertedType}
+
+	r.readStruct(func(id int16, typ byte) {
+		switch id {
+		case 1:
+			e.typ = Type(r.i32())
+			e.hasType = true
+		case 2: //nolint:mnd
+			e.typeLength = r.i32()
+		case 3: //nolint:mnd
+			e.repetition = r.i32()
+		case 4: //nolint:mnd
+			e.name = r.string()
+		case 5: //nolint:mnd
+			e.numChildren = r.i32()
+		case 6: //nolint:mnd
+			e.convertedType = r.i32()
+		case 7: //nolint:mnd
+			e.scale = r.i32()
+		case 10: //nolint:mnd
+			e.logical = readLogicalType(r)
+		default:
+			r.skip(typ)
+		}
+	})
+
+	return e
+}
+
+// readLogicalType decodes the LogicalType union.
+func readLogicalType(r *thriftReader) logicalType {
+	var l logicalType
+
+	r.readStruct(func(id int16, typ byte) {
+		l.ok = true
+
+		switch id {
+		case 1, 4: // STRING, ENUM
+			l.kind = KindString
+		case 5: //nolint:mnd // DECIMAL
+			l.kind = KindDecimal
+			r.readStruct(func(id int16, typ byte) {
+				if id == 1 {
+					l.scale = r.i32()
+				} else {
+					r.skip(typ)
+				}
+			})
+
+			return
+		case 6: //nolint:mnd // DATE
+			l.kind = KindDate
+		case 7, 8: //nolint:mnd // TIME, TIMESTAMP
+			l.kind = KindTime
+			if id == 8 { //nolint:mnd
+				l.kind = KindTimestamp
+			}
+
+			r.readStruct(func(id int16, typ byte) {
+				if id == 2 { //nolint:mnd
+					l.unit = readTimeUnit(r)
+				} else {
+					r.skip(typ)
+				}
+			})
+
+			return
+		case 10: //nolint:mnd // INTEGER
+			r.readStruct(func(id int16, typ byte) {
+				if id == 2 { //nolint:mnd
+					l.unsigned = !r.bool(typ)
+				} else {
+					r.skip(typ)
+				}
+			})
+
+			if l.unsigned {
+				l.kind = KindUnsigned
+			}
+
+			return
+		case 12: //nolint:mnd // JSON
+			l.kind = KindJSON
+		default:
+			// MAP, LIST, UUID and so on are formatted by the physical type
+		}
+
+		r.skip(typ)
+	})
+
+	return l
+}
+
+// readTimeUnit decodes the TimeUnit union.
+func readTimeUnit(r *thriftReader) time.Duration {
+	unit := time.Millisecond
+
+	r.readStruct(func(id int16, typ byte) {
+		switch id {
+		case 2: //nolint:mnd
+			unit = time.Microsecond
+		case 3: //nolint:mnd
+			unit = time.Nanosecond
+		}
+
+		r.skip(typ)
+	})
+
+	return unit
+}
+
+func readRowGroup(r *thriftReader) rowGroup {
+	var rg rowGroup
+
+	r.readStruct(func(id int16, typ byte) {
+		switch id {
+		case 1:
+			r.readList(func(byte) {
+				rg.columns = append(rg.columns, readColumnChunk(r))
+			})
+		case 3: //nolint:mnd
+			rg.numRows = r.varint()
+		default:
+			r.skip(typ)
+		}
+	})
+
+	return rg
+}
+
+func readColumnChunk(r *thriftReader) columnChunk {
+	var c columnChunk
+
+	r.readStruct(func(id int16, typ byte) {
+		if id != 3 { //nolint:mnd
+			r.skip(typ)
+
+			return
+		}
+
+		// ColumnMetaData
+		r.readStruct(func(id int16, typ byte) {
+			switch id {
+			case 4: //nolint:mnd
+				c.codec = Codec(r.i32())
+			case 5: //nolint:mnd
+				c.numValues = r.varint()
+			case 7: //nolint:mnd
+				c.totalCompressedSize = r.varint()
+			case 9: //nolint:mnd
+				c.dataPageOffset = r.varint()
+			case 11: //nolint:mnd
+				c.dictionaryPageOffset = r.varint()
+			default:
+				r.skip(typ)
+			}
+		})
+	})
+
+	return c
+}
+
+// converted types
+const (
+	convertedUTF8            = 0
+	convertedEnum            = 4
+	convertedDecimal         = 5
+	convertedDate            = 6
+	convertedTimeMillis      = 7
+	convertedTimeMicros      = 8
+	convertedTimestampMillis = 9
+	convertedTimestampMicros = 10
+	convertedUint8           = 11
+	convertedUint64          = 14
+	convertedJSON            = 19
+)
+
+// flatColumns returns the columns of a flat schema, whose first element is the root.
+func flatColumns(schema []schemaElement) ([]Column, error) {
+	if len(schema) == 0 {
+		return nil, fmt.Errorf("%w: no schema", ErrCorrupted)
+	}
+
+	if int(schema[0].numChildren) != len(schema)-1 {
+		return nil, fmt.Errorf("%w: nested columns", ErrUnsupported)
+	}
+
+	columns := make([]Column, 0, len(schema)-1)
+
+	for _, e := range schema[1:] {
+		if e.numChildren > 0 || !e.hasType {
+			return nil, fmt.Errorf("%w: nested column %q", ErrUnsupported, e.name)
+		}
+
+		if e.repetition == repetitionRepeated {
+			return nil, fmt.Errorf("%w: repeated column %q", ErrUnsupported, e.name)
+		}
+
+		if e.typ < Boolean || e.typ > FixedLenByteArray {
+			return nil, fmt.Errorf("%w: column %q has unknown type %d", ErrCorrupted, e.name, e.typ)
+		}
+
+		c := Column{
+			Name:     e.name,
+			Type:     e.typ,
+			Optional: e.repetition == repetitionOptional,
+			Length:   e.typeLength,
+			Scale:    e.scale,
+		}
+
+		switch {
+		case e.logical.ok:
+			c.Kind = e.logical.kind
+			c.Unit = e.logical.unit
+
+			if c.Kind == KindDecimal {
+				c.Scale = e.logical.scale
+			}
+		default:
+			c.Kind, c.Unit = convertedKind(e.convertedType)
+		}
+
+		if c.Type == Int96 {
+			c.Kind = KindTimestamp
+		}
+
+		columns = append(columns, c)
+	}
+
+	return columns, nil
+}
+
+func convertedKind(convertedType int32) (Kind, time.Duration) {
+	switch convertedType {
+	case convertedUTF8, convertedEnum:
+		return KindString, 0
+	case convertedJSON:
+		return KindJSON, 0
+	case convertedDecimal:
+		return KindDecimal, 0
+	case convertedDate:
+		return KindDate, 0
+	case convertedTimeMillis:
+		return KindTime, time.Millisecond
+	case convertedTimeMicros:
+		return KindTime, time.Microsecond
+	case convertedTimestampMillis:
+		return KindTimestamp, time.Millisecond
+	case convertedTimestampMicros:
+		return KindTimestamp, time.Microsecond
+	}
+
+	if convertedType >= convertedUint8 && convertedType <= convertedUint64 {
+		return KindUnsigned, 0
+	}
+
+	return KindPlain, 0
+}
+
+// RowReader reads rows of all row groups in order.
+type RowReader struct {
+	file    *File
+	group   int
+	columns [][]string
+	row     int
+	rows    int
+	count   int64
+}
+
+func (f *File) NewRowReader() *RowReader {
+	return &RowReader{file: f}
+}
+
+// Read returns the values of the next row in column order, and io.EOF at the end of the file.
+func (r *RowReader) Read() ([]string, error) {
+	for r.row == r.rows {
+		if r.group == len(r.file.rowGroups) {
+			return nil, io.EOF
+		}
+
+		columns, err := r.file.ReadRowGroup(r.group)
+		if err != nil {
+			return nil, err
+		}
+
+		r.columns = columns
+		r.rows = int(r.file.rowGroups[r.group].numRows)
+		r.row = 0
+		r.group++
+	}
+
+	record := make([]string, len(r.columns))
+	for i, values := range r.columns {
+		record[i] = values[r.row]
+	}
+
+	r.row++
+	r.count++
+
+	return record, nil
+}
+
+// Row returns the 1-based number of the last read row.
+func (r *RowReader) Row() int64 {
+	return r.count
+}
+
+// ReadRowGroup returns the values of each column of the row group.
+func (f *File) ReadRowGroup(i int) ([][]string, error) {
+	rg := f.rowGroups[i]
+
+	columns := make([][]string, len(f.columns))
+
+	for j := range f.columns {
+		values, err := f.readColumn(&f.columns[j], rg.columns[j], rg.numRows)
+		if err != nil {
+			return nil, fmt.Errorf("row group %d, column %q: %w", i, f.columns[j].Name, err)
+		}
+
+		columns[j] = values
+	}
+
+	return columns, nil
+}
@@ -0,0 +1,277 @@
+package parquet_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yokomotod/yuccadb/internals/parquettest"
+	"github.com/yokomotod/yuccadb/parquet"
+)
+
+func write(t *testing.T, columns []parquet.Column, opts parquettest.WriterOptions, rows [][]any) *bytes.Reader {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	w, err := parquettest.NewWriter(&buf, columns, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return bytes.NewReader(buf.Bytes())
+}
+
+func readAll(t *testing.T, r *bytes.Reader) (*parquet.File, [][]string) {
+	t.Helper()
+
+	f, err := parquet.Open(r, r.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rows [][]string
+
+	reader := f.NewRowReader()
+
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return f, rows
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	columns := []parquet.Column{
+		{Name: "key", Type: parquet.ByteArray, Kind: parquet.KindString},
+		{Name: "flag", Type: parquet.Boolean, Optional: true},
+		{Name: "i32", Type: parquet.Int32},
+		{Name: "i64", Type: parquet.Int64, Optional: true},
+		{Name: "f32", Type: parquet.Float},
+		{Name: "f64", Type: parquet.Double},
+		{Name: "date", Type: parquet.Int32, Kind: parquet.KindDate},
+		{Name: "ts", Type: parquet.Int64, Kind: parquet.KindTimestamp, Unit: time.Microsecond, Optional: true},
+		{Name: "json", Type: parquet.ByteArray, Kind: parquet.KindJSON, Optional: true},
+		{Name: "bin", Type: parquet.ByteArray},
+	}
+
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+
+	rows := [][]any{
+		{"a", true, int32(-1), int64(1) << 40, float32(1.5), 0.25, ts, ts, `{"x":1}`, []byte{0xff}},
+		{"b", nil, int32(2), nil, float32(-2), 1e100, ts.AddDate(0, 0, 1), nil, nil, []byte{}},
+		{"c", false, int32(3), int64(-3), float32(0), -0.5, time.Unix(0, 0), ts.Add(time.Second), `[]`, []byte("x")},
+	}
+
+	want := [][]string{
+		{"a", "true", "-1", "1099511627776", "1.5", "0.25", "2024-01-02", "2024-01-02T03:04:05.000006Z", `{"x":1}`, "/w=="},
+		{"b", "", "2", "", "-2", "1e+100", "2024-01-03", "", "", ""},
+		{"c", "false", "3", "-3", "0", "-0.5", "1970-01-01", "2024-01-02T03:04:06.000006Z", "[]", "eA=="},
+	}
+
+	tests := []parquettest.WriterOptions{
+		{},
+		{Dictionary: true},
+		{PageV2: true},
+		{Compression: parquet.Gzip, Dictionary: true, PageV2: true},
+		{Compression: parquet.Gzip, RowGroupRows: 2},
+		{RowGroupRows: 1, Dictionary: true},
+	}
+
+	for _, opts := range tests {
+		t.Run(fmt.Sprintf("%+v", opts), func(t *testing.T) {
+			t.Parallel()
+
+			f, got := readAll(t, write(t, columns, opts, rows))
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("rows = %v, want %v", got, want)
+			}
+
+			if !reflect.DeepEqual(f.Columns(), columns) {
+				t.Errorf("columns = %v, want %v", f.Columns(), columns)
+			}
+
+			if f.NumRows() != int64(len(rows)) {
+				t.Errorf("NumRows() = %d, want %d", f.NumRows(), len(rows))
+			}
+		})
+	}
+}
+
+func TestPyarrow(t *testing.T) {
+	t.Parallel()
+
+	data, err := os.ReadFile("testdata/pyarrow-0.7.1.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, rows := readAll(t, bytes.NewReader(data))
+
+	var names []string
+
+	for _, c := range f.Columns() {
+		if !c.Optional {
+			t.Errorf("column %q is not optional", c.Name)
+		}
+
+		names = append(names, c.Name)
+	}
+
+	wantNames := []string{"carat", "cut", "color", "clarity", "depth", "table", "price", "x", "y", "z", "__index_level_0__"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Fatalf("expected columns %v, but got %v", wantNames, names)
+	}
+
+	// as printed by the parquet_reader of Apache Arrow
+	want := [][]string{
+		{"0.23", "Ideal", "E", "SI2", "61.5", "55", "326", "3.95", "3.98", "2.43", "0"},
+		{"0.21", "Premium", "E", "SI1", "59.8", "61", "326", "3.89", "3.84", "2.31", "1"},
+		{"0.23", "Good", "E", "VS1", "56.9", "65", "327", "4.05", "4.07", "2.31", "2"},
+		{"0.29", "Premium", "I", "VS2", "62.4", "58", "334", "4.2", "4.23", "2.63", "3"},
+		{"0.31", "Good", "J", "SI2", "63.3", "58", "335", "4.34", "4.35", "2.75", "4"},
+		{"0.24", "Very Good", "J", "VVS2", "62.8", "57", "336", "3.94", "3.96", "2.48", "5"},
+		{"0.24", "Very Good", "I", "VVS1", "62.3", "57", "336", "3.95", "3.98", "2.47", "6"},
+		{"0.26", "Very Good", "H", "SI1", "61.9", "55", "337", "4.07", "4.11", "2.53", "7"},
+		{"0.22", "Fair", "E", "VS2", "65.1", "61", "337", "3.87", "3.78", "2.49", "8"},
+		{"0.23", "Very Good", "H", "VS1", "59.4", "61", "338", "4", "4.05", "2.39", "9"},
+	}
+
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("expected %q, but got %q", want, rows)
+	}
+}
+
+func TestAllNulls(t *testing.T) {
+	t.Parallel()
+
+	columns := []parquet.Column{
+		{Name: "key", Type: parquet.ByteArray, Kind: parquet.KindString},
+		{Name: "value", Type: parquet.ByteArray, Kind: parquet.KindString, Optional: true},
+	}
+
+	_, got := readAll(t, write(t, columns, parquettest.WriterOptions{Dictionary: true}, [][]any{{"a", nil}, {"b", nil}}))
+
+	want := [][]string{{"a", ""}, {"b", ""}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	t.Parallel()
+
+	column := parquet.Column{Name: "key", Type: parquet.ByteArray, Kind: parquet.KindString}
+
+	if _, err := parquettest.NewWriter(io.Discard, []parquet.Column{column}, parquettest.WriterOptions{Compression: parquet.Snappy}); !errors.Is(err, parquet.ErrUnsupported) {
+		t.Errorf("snappy: got %v, want ErrUnsupported", err)
+	}
+
+	decimal := parquet.Column{Name: "d", Type: parquet.Int64, Kind: parquet.KindDecimal}
+	if _, err := parquettest.NewWriter(io.Discard, []parquet.Column{decimal}, parquettest.WriterOptions{}); !errors.Is(err, parquet.ErrUnsupported) {
+		t.Errorf("decimal: got %v, want ErrUnsupported", err)
+	}
+
+	w, err := parquettest.NewWriter(io.Discard, []parquet.Column{column}, parquettest.WriterOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, row := range [][]any{{nil}, {1}, {"a", "b"}} {
+		if err := w.Write(row); err == nil {
+			t.Errorf("Write(%v): expected error", row)
+		}
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	t.Parallel()
+
+	columns := []parquet.Column{{Name: "key", Type: parquet.ByteArray, Kind: parquet.KindString}}
+	valid := write(t, columns, parquettest.WriterOptions{}, [][]any{{"a"}})
+
+	data := make([]byte, valid.Size())
+	if _, err := valid.ReadAt(data, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"bad magic", append([]byte("PAR0"), data[4:]...)},
+		{"truncated", data[:len(data)-1]},
+		{"bad footer length", append(append(data[:len(data)-8:len(data)-8], 0xff, 0xff, 0xff, 0x7f), "PAR1"...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := parquet.Open(bytes.NewReader(tt.data), int64(len(tt.data))); !errors.Is(err, parquet.ErrCorrupted) {
+				t.Errorf("got %v, want ErrCorrupted", err)
+			}
+		})
+	}
+}
+
+func TestSnappyDecode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		src     []byte
+		want    string
+		wantErr bool
+	}{
+		{"literal", []byte{0x03, 0x08, 'a', 'b', 'c'}, "abc", false},
+		{"overlapping copy", []byte{0x0c, 0x08, 'a', 'b', 'c', 0x15, 0x03}, "abcabcabcabc", false},
+		{"2-byte offset copy", []byte{0x05, 0x04, 'a', 'b', 0x0a, 0x02, 0x00}, "ababa", false},
+		{"bad offset", []byte{0x07, 0x08, 'a', 'b', 'c', 0x01, 0x04}, "", true},
+		{"bad length", []byte{0x04, 0x08, 'a', 'b', 'c'}, "", true},
+		{"short literal", []byte{0x03, 0x08, 'a'}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parquet.SnappyDecode(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// types of the thrift compact protocol, in which Parquet metadata is encoded.
+const (
+	typeStop   = 0
+	typeTrue   = 1
+	typeFalse  = 2
+	typeByte   = 3
+	typeI16    = 4
+	typeI32    = 5
+	typeI64    = 6
+	typeDouble = 7
+	typeBinary = 8
+	typeList   = 9
+	typeSet    = 10
+	typeMap    = 11
+	typeStruct = 12
+)
+
+// maxStructDepth bounds nesting so that corrupted metadata cannot exhaust the stack.
+const maxStructDepth = 64
+
+// thriftReader decodes the compact protocol, remembering the first error
+// so that callers can check it once at the end.
+type thriftReader struct {
+	buf   []byte
+	depth int
+	err   error
+}
+
+func (r *thriftReader) fail(msg string) {
+	if r.err == nil {
+		r.err = fmt.Errorf("%w: %s", ErrCorrupted, msg)
+	}
+
+	r.buf = nil
+}
+
+func (r *thriftReader) byte() byte {
+	if len(r.buf) == 0 {
+		r.fail("unexpected end of metadata")
+
+		return 0
+	}
+
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.fail("bad varint")
+
+		return 0
+	}
+
+	r.buf = r.buf[n:]
+
+	return v
+}
+
+// varint reads a zigzag encoded integer.
+func (r *thriftReader) varint() int64 {
+	u := r.uvarint()
+
+	return int64(u>>1) ^ -int64(u&1) //nolint:gosec
+}
+
+func (r *thriftReader) i32() int32 {
+	return int32(r.varint()) //nolint:gosec
+}
+
+func (r *thriftReader) bytes(n uint64) []byte {
+	if n > uint64(len(r.buf)) {
+		r.fail("unexpected end of metadata")
+
+		return nil
+	}
+
+	v := r.buf[:n]
+	r.buf = r.buf[n:]
+
+	return v
+}
+
+func (r *thriftReader) binary() []byte {
+	return r.bytes(r.uvarint())
+}
+
+func (r *thriftReader) string() string {
+	return string(r.binary())
+}
+
+// readStruct calls field for each field until the stop field.
+// field must read or skip the value of the type.
+func (r *thriftReader) readStruct(field func(id int16, typ byte)) {
+	r.depth++
+	defer func() { r.depth-- }()
+
+	if r.depth > maxStructDepth {
+		r.fail("too deeply nested")
+
+		return
+	}
+
+	var last int16
+
+	for r.err == nil {
+		b := r.byte()
+		typ := b & 0x0f //nolint:mnd
+
+		if typ == typeStop {
+			return
+		}
+
+		id := last + int16(b>>4) //nolint:mnd
+		if b>>4 == 0 {
+			id = int16(r.varint()) //nolint:gosec
+		}
+
+		last = id
+
+		field(id, typ)
+	}
+}
+
+// listHeader returns the element type and the number of elements.
+func (r *thriftReader) listHeader() (byte, int) {
+	b := r.byte()
+
+	n := uint64(b >> 4) //nolint:mnd
+	if n == 15 {        //nolint:mnd
+		n = r.uvarint()
+	}
+
+	// every element takes at least a byte
+	if n > uint64(len(r.buf)) {
+		r.fail("bad list size")
+
+		return 0, 0
+	}
+
+	return b & 0x0f, int(n) //nolint:mnd
+}
+
+// readList calls elem for each element of a list field.
+func (r *thriftReader) readList(elem func(typ byte)) {
+	typ, n := r.listHeader()
+	for i := 0; i < n && r.err == nil; i++ {
+		elem(typ)
+	}
+}
+
+// bool returns the value of a bool field, which is encoded in its type.
+func (r *thriftReader) bool(typ byte) bool {
+	return typ == typeTrue
+}
+
+func (r *thriftReader) skip(typ byte) {
+	switch typ {
+	case typeTrue, typeFalse:
+		// the value is the type
+	case typeByte:
+		r.byte()
+	case typeI16, typeI32, typeI64:
+		r.uvarint()
+	case typeDouble:
+		r.bytes(8) //nolint:mnd
+	case typeBinary:
+		r.binary()
+	case typeList, typeSet:
+		r.readList(func(typ byte) {
+			if typ == typeTrue || typ == typeFalse {
+				// bool elements are a byte each
+				r.byte()
+			} else {
+				r.skip(typ)
+			}
+		})
+	case typeMap:
+		n := r.uvarint()
+		if n == 0 {
+			return
+		}
+
+		types := r.byte()
+		for i := uint64(0); i < n && r.err == nil; i++ {
+			r.skip(types >> 4) //nolint:mnd
+			r.skip(types & 0x0f)
+		}
+	case typeStruct:
+		r.readStruct(func(_ int16, typ byte) {
+			r.skip(typ)
+		})
+	default:
+		r.fail(fmt.Sprintf("unknown type %d", typ))
+	}
+}
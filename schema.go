@@ -0,0 +1,105 @@
+package yuccadb
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+var ErrSchemaChanged = errors.New("table schema has been changed")
+
+// SchemaChange describes how a table put by PutTable differs from the table it replaces.
+// Columns and Schema are nil for tables without them, as returned by TableColumns and TableSchema,
+// and Fields is the number of fields of every row as in yuccaTable.Stats.
+type SchemaChange struct {
+	Table                  string
+	OldColumns, NewColumns []string
+	OldSchema, NewSchema   yuccaTable.Schema
+	OldFields, NewFields   int
+}
+
+// String returns the differences only, e.g. `table "users": fields 3 -> 4, columns [id name] -> [id name age]`.
+func (c SchemaChange) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "table %q", c.Table)
+
+	sep := ": "
+
+	if c.OldFields != c.NewFields {
+		fmt.Fprintf(&b, "%sfields %d -> %d", sep, c.OldFields, c.NewFields)
+		sep = ", "
+	}
+
+	if !slices.Equal(c.OldColumns, c.NewColumns) {
+		fmt.Fprintf(&b, "%scolumns %v -> %v", sep, c.OldColumns, c.NewColumns)
+		sep = ", "
+	}
+
+	if !slices.Equal(c.OldSchema, c.NewSchema) {
+		fmt.Fprintf(&b, "%sschema %v -> %v", sep, c.OldSchema, c.NewSchema)
+	}
+
+	return b.String()
+}
+
+func (c SchemaChange) changed() bool {
+	return c.OldFields != c.NewFields ||
+		!slices.Equal(c.OldColumns, c.NewColumns) ||
+		!slices.Equal(c.OldSchema, c.NewSchema)
+}
+
+// RejectSchemaChange is an OnSchemaChange function which refuses every change with ErrSchemaChanged.
+func RejectSchemaChange(change SchemaChange) error {
+	return fmt.Errorf("%w: %s", ErrSchemaChanged, change)
+}
+
+func newSchemaChange(tableName string, oldTable, newTable yuccaTable.TableReader) SchemaChange {
+	change := SchemaChange{
+		Table:     tableName,
+		OldFields: oldTable.Stats().Fields,
+		NewFields: newTable.Stats().Fields,
+	}
+
+	if table, ok := oldTable.(columnLister); ok {
+		change.OldColumns = table.Columns()
+	}
+
+	if table, ok := newTable.(columnLister); ok {
+		change.NewColumns = table.Columns()
+	}
+
+	if table, ok := oldTable.(schemaReader); ok {
+		change.OldSchema = table.Schema()
+	}
+
+	if table, ok := newTable.(schemaReader); ok {
+		change.NewSchema = table.Schema()
+	}
+
+	return change
+}
+
+// checkSchemaChange compares the table with the replaced one, and calls OnSchemaChange if they differ.
+// oldHandle is nil if there is no table to replace.
+func (db *YuccaDB) checkSchemaChange(tableName string, oldHandle *tableHandle, table yuccaTable.TableReader) error {
+	if oldHandle == nil {
+		return nil
+	}
+
+	change := newSchemaChange(tableName, oldHandle.table, table)
+	if !change.changed() {
+		return nil
+	}
+
+	db.Logger.Infof("Replacing a table of a different schema, %s", change)
+
+	if db.OnSchemaChange == nil {
+		return nil
+	}
+
+	return db.OnSchemaChange(change)
+}
@@ -0,0 +1,119 @@
+package sstable
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// limits to detect corrupted rows before allocating.
+const (
+	maxColumns   = 1 << 16
+	maxFieldSize = 1 << 30
+)
+
+// RowReader decodes rows sequentially from a reader positioned at a row,
+// e.g. a section of the data blocks from a block offset.
+type RowReader struct {
+	reader      *bufio.Reader
+	reuseRecord bool
+	record      []string
+	buf         []byte
+	offset      int64
+	row         int
+}
+
+// NewRowReader returns a RowReader. If reuseRecord is true,
+// the slice returned by Read is reused by the next Read like csv.Reader.ReuseRecord.
+func NewRowReader(r io.Reader, reuseRecord bool) *RowReader {
+	return &RowReader{
+		reader:      bufio.NewReader(r),
+		reuseRecord: reuseRecord,
+	}
+}
+
+// Read returns the key followed by the values of the next row,
+// and io.EOF at the end of the reader.
+func (r *RowReader) Read() ([]string, error) {
+	n, err := r.uvarint()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+
+		return nil, err
+	}
+
+	if n == 0 || n > maxColumns {
+		return nil, fmt.Errorf("%w: bad number of columns %d", ErrCorrupted, n)
+	}
+
+	record := r.record[:0]
+	if !r.reuseRecord {
+		record = make([]string, 0, n)
+	}
+
+	for range n {
+		length, err := r.uvarint()
+		if err != nil {
+			return nil, r.unexpected(err)
+		}
+
+		if length > maxFieldSize {
+			return nil, fmt.Errorf("%w: bad field length %d", ErrCorrupted, length)
+		}
+
+		if cap(r.buf) < int(length) { //nolint:gosec
+			r.buf = make([]byte, length)
+		}
+
+		buf := r.buf[:length]
+		if _, err := io.ReadFull(r.reader, buf); err != nil {
+			return nil, r.unexpected(err)
+		}
+
+		r.offset += int64(length) //nolint:gosec
+		record = append(record, string(buf))
+	}
+
+	if r.reuseRecord {
+		r.record = record
+	}
+
+	r.row++
+
+	return record, nil
+}
+
+func (r *RowReader) uvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(r.reader)
+	if err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+
+	var buf [binary.MaxVarintLen64]byte
+	r.offset += int64(binary.PutUvarint(buf[:], v))
+
+	return v, nil
+}
+
+// unexpected converts EOF in the middle of a row to ErrCorrupted.
+func (r *RowReader) unexpected(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: unexpected end of row", ErrCorrupted)
+	}
+
+	return err
+}
+
+// InputOffset returns the offset of the end of the last read row from the start of the reader.
+func (r *RowReader) InputOffset() int64 {
+	return r.offset
+}
+
+// FieldPos returns the 1-based number of the last read row as the line, like csv.Reader.FieldPos.
+func (r *RowReader) FieldPos(int) (int, int) {
+	return r.row, 1
+}
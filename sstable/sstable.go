@@ -0,0 +1,259 @@
+// Package sstable implements a block-based binary format of sorted rows,
+// which is cheaper to read than CSV since rows are length-prefixed.
+//
+// File layout (integers are uvarint unless noted):
+//
+//	data blocks | index | footer
+//
+//	row:    number of columns, then (length, bytes) for each column. The first column is the key.
+//	block:  rows, at most BlockRows rows or a little over BlockBytes bytes.
+//	index:  number of blocks, then (first key length, first key, offset, size, rows, crc32 uint32) for each block,
+//	        then count, last key length, last key, offset of the first row of the last key, max block rows,
+//	        and number of column names, then (length, name) for each column,
+//	        then optionally the source size, source modification time varint and source crc32 uint32.
+//	footer: index offset uint64 | index size uint64 | crc32(index) uint32 | version uint16 | magic "YSST"
+//
+// Fixed size integers are little endian.
+package sstable
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	magic      = "YSST"
+	version    = 1
+	footerSize = 8 + 8 + 4 + 2 + len(magic)
+)
+
+var (
+	ErrCorrupted = errors.New("sstable is corrupted")
+	ErrVersion   = errors.New("unsupported sstable version")
+)
+
+// BlockHandle locates a data block.
+type BlockHandle struct {
+	FirstKey string
+	Offset   int64
+	Size     int64
+	Rows     int64
+	Checksum uint32
+}
+
+// Source identifies the version of the file which an sstable was converted from.
+type Source struct {
+	Size int64
+	// ModTime is the modification time in Unix nanoseconds.
+	ModTime  int64
+	Checksum uint32
+}
+
+// Metadata is the index and the metadata of a file, read from the end of the file.
+type Metadata struct {
+	Blocks []BlockHandle
+	Count  int64
+	// LastKey and LastOffset locate the first row of the last key.
+	LastKey      string
+	LastOffset   int64
+	MaxBlockRows int64
+	// Columns names the key and the value columns, or nil if not named.
+	Columns []string
+	// DataSize is the size of the data blocks, which start at offset 0.
+	DataSize int64
+	// Source is the zero Source if the file was not written with one.
+	Source Source
+}
+
+// ReadMetadata reads the footer and the index of the file of size.
+func ReadMetadata(r io.ReaderAt, size int64) (*Metadata, error) {
+	if size < int64(footerSize) {
+		return nil, fmt.Errorf("%w: file is too small", ErrCorrupted)
+	}
+
+	footer := make([]byte, footerSize)
+	if _, err := r.ReadAt(footer, size-int64(footerSize)); err != nil {
+		return nil, fmt.Errorf("ReadAt: %w", err)
+	}
+
+	if string(footer[footerSize-len(magic):]) != magic {
+		return nil, fmt.Errorf("%w: bad magic", ErrCorrupted)
+	}
+
+	if v := binary.LittleEndian.Uint16(footer[20:]); v != version {
+		return nil, fmt.Errorf("%w: %d", ErrVersion, v)
+	}
+
+	indexOffset := int64(binary.LittleEndian.Uint64(footer[0:]))
+	indexSize := int64(binary.LittleEndian.Uint64(footer[8:]))
+	indexChecksum := binary.LittleEndian.Uint32(footer[16:])
+
+	if indexOffset < 0 || indexSize < 0 || indexOffset+indexSize != size-int64(footerSize) {
+		return nil, fmt.Errorf("%w: bad index location", ErrCorrupted)
+	}
+
+	index := make([]byte, indexSize)
+	if _, err := r.ReadAt(index, indexOffset); err != nil {
+		return nil, fmt.Errorf("ReadAt: %w", err)
+	}
+
+	if crc32.ChecksumIEEE(index) != indexChecksum {
+		return nil, fmt.Errorf("%w: index checksum mismatch", ErrCorrupted)
+	}
+
+	meta, err := decodeIndex(index)
+	if err != nil {
+		return nil, err
+	}
+
+	meta.DataSize = indexOffset
+
+	return meta, nil
+}
+
+func decodeIndex(buf []byte) (*Metadata, error) {
+	dec := decoder{buf: buf}
+	meta := &Metadata{}
+
+	meta.Blocks = make([]BlockHandle, dec.length())
+	for i := range meta.Blocks {
+		meta.Blocks[i] = BlockHandle{
+			FirstKey: dec.string(),
+			Offset:   dec.int(),
+			Size:     dec.int(),
+			Rows:     dec.int(),
+			Checksum: dec.uint32(),
+		}
+	}
+
+	meta.Count = dec.int()
+	meta.LastKey = dec.string()
+	meta.LastOffset = dec.int()
+	meta.MaxBlockRows = dec.int()
+
+	if n := dec.length(); n > 0 {
+		meta.Columns = make([]string, n)
+		for i := range meta.Columns {
+			meta.Columns[i] = dec.string()
+		}
+	}
+
+	if len(dec.buf) > 0 {
+		meta.Source = Source{
+			Size:     dec.int(),
+			ModTime:  dec.varint(),
+			Checksum: dec.uint32(),
+		}
+	}
+
+	if dec.err != nil {
+		return nil, dec.err
+	}
+
+	return meta, nil
+}
+
+// VerifyBlocks reads all data blocks and compares their checksums.
+func (m *Metadata) VerifyBlocks(r io.ReaderAt) error {
+	for i, block := range m.Blocks {
+		hash := crc32.NewIEEE()
+		if _, err := io.Copy(hash, io.NewSectionReader(r, block.Offset, block.Size)); err != nil {
+			return fmt.Errorf("io.Copy: %w", err)
+		}
+
+		if hash.Sum32() != block.Checksum {
+			return fmt.Errorf("%w: block %d checksum mismatch", ErrCorrupted, i)
+		}
+	}
+
+	return nil
+}
+
+// decoder reads the index, remembering the first error
+// so that callers can check it once at the end.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: unexpected end of index", ErrCorrupted)
+	}
+
+	d.buf = nil
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.fail()
+
+		return 0
+	}
+
+	d.buf = d.buf[n:]
+
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.fail()
+
+		return 0
+	}
+
+	d.buf = d.buf[n:]
+
+	return v
+}
+
+func (d *decoder) int() int64 {
+	return int64(d.uvarint()) //nolint:gosec
+}
+
+// length reads a length which must fit in the rest of the buffer,
+// so that corrupted lengths never allocate huge slices.
+func (d *decoder) length() int {
+	n := d.uvarint()
+	if n > uint64(len(d.buf)) {
+		d.fail()
+
+		return 0
+	}
+
+	return int(n)
+}
+
+func (d *decoder) string() string {
+	n := d.length()
+	v := string(d.buf[:n])
+	d.buf = d.buf[n:]
+
+	return v
+}
+
+func (d *decoder) uint32() uint32 {
+	if len(d.buf) < 4 { //nolint:mnd
+		d.fail()
+
+		return 0
+	}
+
+	v := binary.LittleEndian.Uint32(d.buf)
+	d.buf = d.buf[4:]
+
+	return v
+}
+
+// appendString appends a length-prefixed string.
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+
+	return append(buf, s...)
+}
@@ -0,0 +1,160 @@
+package sstable_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/yokomotod/yuccadb/sstable"
+)
+
+func writeTable(t *testing.T, n int, opts sstable.WriterOptions) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	w := sstable.NewWriter(&buf, opts)
+	for i := range n {
+		if err := w.Add(fmt.Sprintf("%04d", i), []string{fmt.Sprint(i), ""}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	data := writeTable(t, 100, sstable.WriterOptions{BlockRows: 7, Columns: []string{"id", "v", "empty"}})
+
+	meta, err := sstable.ReadMetadata(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if meta.Count != 100 || len(meta.Blocks) != 15 || meta.MaxBlockRows != 7 || meta.LastKey != "0099" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+
+	if !reflect.DeepEqual(meta.Columns, []string{"id", "v", "empty"}) {
+		t.Fatalf("unexpected columns: %v", meta.Columns)
+	}
+
+	if meta.Source != (sstable.Source{}) {
+		t.Fatalf("unexpected source: %+v", meta.Source)
+	}
+
+	if err := meta.VerifyBlocks(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	// read from the third block
+	block := meta.Blocks[2]
+	r := sstable.NewRowReader(bytes.NewReader(data[block.Offset:meta.DataSize]), true)
+
+	for i := 14; i < 100; i++ {
+		row, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := []string{fmt.Sprintf("%04d", i), fmt.Sprint(i), ""}; !reflect.DeepEqual(row, want) {
+			t.Fatalf("expected %q, but got %q", want, row)
+		}
+	}
+
+	if _, err := r.Read(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF, but got %v", err)
+	}
+
+	if r.InputOffset() != meta.DataSize-block.Offset {
+		t.Fatalf("unexpected offset %d", r.InputOffset())
+	}
+}
+
+func TestSource(t *testing.T) {
+	t.Parallel()
+
+	source := sstable.Source{Size: 1234, ModTime: 1_700_000_000_123_456_789, Checksum: 0xdeadbeef}
+	data := writeTable(t, 10, sstable.WriterOptions{Columns: []string{"id", "v", "empty"}, Source: source})
+
+	meta, err := sstable.ReadMetadata(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if meta.Source != source {
+		t.Fatalf("expected source %+v, but got %+v", source, meta.Source)
+	}
+
+	if !reflect.DeepEqual(meta.Columns, []string{"id", "v", "empty"}) {
+		t.Fatalf("unexpected columns: %v", meta.Columns)
+	}
+}
+
+func TestBlockBytes(t *testing.T) {
+	t.Parallel()
+
+	data := writeTable(t, 100, sstable.WriterOptions{BlockBytes: 64})
+
+	meta, err := sstable.ReadMetadata(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, block := range meta.Blocks {
+		// a block ends with the first row over the bytes
+		if block.Size >= 64+16 {
+			t.Fatalf("block is too large: %+v", block)
+		}
+	}
+}
+
+func TestCorrupted(t *testing.T) {
+	t.Parallel()
+
+	data := writeTable(t, 100, sstable.WriterOptions{BlockRows: 10})
+
+	// a data block
+	corrupted := bytes.Clone(data)
+	corrupted[5] ^= 0xff
+
+	meta, err := sstable.ReadMetadata(bytes.NewReader(corrupted), int64(len(corrupted)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := meta.VerifyBlocks(bytes.NewReader(corrupted)); !errors.Is(err, sstable.ErrCorrupted) {
+		t.Fatalf("expected ErrCorrupted, but got %v", err)
+	}
+
+	// the index
+	corrupted = bytes.Clone(data)
+	corrupted[len(corrupted)-30] ^= 0xff
+
+	if _, err := sstable.ReadMetadata(bytes.NewReader(corrupted), int64(len(corrupted))); !errors.Is(err, sstable.ErrCorrupted) {
+		t.Fatalf("expected ErrCorrupted, but got %v", err)
+	}
+}
+
+func TestKeysNotSorted(t *testing.T) {
+	t.Parallel()
+
+	w := sstable.NewWriter(io.Discard, sstable.WriterOptions{})
+
+	if err := w.Add("b", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Add("a", nil); !errors.Is(err, sstable.ErrKeysNotSorted) {
+		t.Fatalf("expected ErrKeysNotSorted, but got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package sstable
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	defaultBlockRows  = 1_000
+	defaultBlockBytes = 4 << 10
+)
+
+type WriterOptions struct {
+	// BlockRows is the max number of rows in a block. Default is 1,000.
+	BlockRows int64
+	// BlockBytes starts a new block once a block reaches the bytes. Default is 4 KiB.
+	BlockBytes int64
+	// Columns names the key and the value columns, e.g. for Table.Columns.
+	Columns []string
+	// Source identifies the file which the rows were converted from, if any.
+	Source Source
+}
+
+var ErrKeysNotSorted = errors.New("keys are not sorted")
+
+// Writer writes rows in ascending key order.
+// Close must be called to write the index.
+type Writer struct {
+	w    *bufio.Writer
+	opts WriterOptions
+
+	offset int64
+	buf    []byte
+	blocks []BlockHandle
+	block  *BlockHandle
+	hash   hash.Hash32
+
+	count        int64
+	lastKey      string
+	lastOffset   int64
+	maxBlockRows int64
+}
+
+func NewWriter(w io.Writer, opts WriterOptions) *Writer {
+	if opts.BlockRows <= 0 {
+		opts.BlockRows = defaultBlockRows
+	}
+
+	if opts.BlockBytes <= 0 {
+		opts.BlockBytes = defaultBlockBytes
+	}
+
+	return &Writer{
+		w:    bufio.NewWriter(w),
+		opts: opts,
+		hash: crc32.NewIEEE(),
+	}
+}
+
+// Add writes a row. Keys must be added in ascending order.
+// Equal keys are allowed, and are kept in the same block even if it exceeds BlockRows or BlockBytes.
+func (w *Writer) Add(key string, values []string) error {
+	if w.count > 0 && key < w.lastKey {
+		return fmt.Errorf("%w: %q, %q", ErrKeysNotSorted, w.lastKey, key)
+	}
+
+	duplicate := w.count > 0 && key == w.lastKey
+
+	if w.block == nil || (!duplicate && (w.block.Rows >= w.opts.BlockRows || w.block.Size >= w.opts.BlockBytes)) {
+		w.finishBlock()
+		w.block = &BlockHandle{FirstKey: key, Offset: w.offset}
+	}
+
+	buf := binary.AppendUvarint(w.buf[:0], uint64(len(values)+1))
+	buf = appendString(buf, key)
+
+	for _, v := range values {
+		buf = appendString(buf, v)
+	}
+
+	w.buf = buf
+
+	if _, err := w.w.Write(buf); err != nil {
+		return fmt.Errorf("Write: %w", err)
+	}
+
+	w.hash.Write(buf)
+
+	if !duplicate {
+		w.lastOffset = w.offset
+	}
+
+	w.lastKey = key
+	w.offset += int64(len(buf))
+	w.count++
+	w.block.Size += int64(len(buf))
+	w.block.Rows++
+
+	return nil
+}
+
+func (w *Writer) finishBlock() {
+	if w.block == nil {
+		return
+	}
+
+	w.block.Checksum = w.hash.Sum32()
+	w.hash.Reset()
+	w.blocks = append(w.blocks, *w.block)
+	w.maxBlockRows = max(w.maxBlockRows, w.block.Rows)
+}
+
+// Close writes the index and the footer, and flushes.
+// It does not close the underlying writer.
+func (w *Writer) Close() error {
+	w.finishBlock()
+	w.block = nil
+
+	index := binary.AppendUvarint(nil, uint64(len(w.blocks)))
+	for _, block := range w.blocks {
+		index = appendString(index, block.FirstKey)
+		index = binary.AppendUvarint(index, uint64(block.Offset))
+		index = binary.AppendUvarint(index, uint64(block.Size))
+		index = binary.AppendUvarint(index, uint64(block.Rows))
+		index = binary.LittleEndian.AppendUint32(index, block.Checksum)
+	}
+
+	index = binary.AppendUvarint(index, uint64(w.count))
+	index = appendString(index, w.lastKey)
+	index = binary.AppendUvarint(index, uint64(w.lastOffset))
+	index = binary.AppendUvarint(index, uint64(w.maxBlockRows))
+
+	index = binary.AppendUvarint(index, uint64(len(w.opts.Columns)))
+	for _, column := range w.opts.Columns {
+		index = appendString(index, column)
+	}
+
+	// written only if set, so that files without a source stay readable by older readers
+	if w.opts.Source != (Source{}) {
+		index = binary.AppendUvarint(index, uint64(w.opts.Source.Size))
+		index = binary.AppendVarint(index, w.opts.Source.ModTime)
+		index = binary.LittleEndian.AppendUint32(index, w.opts.Source.Checksum)
+	}
+
+	footer := binary.LittleEndian.AppendUint64(nil, uint64(w.offset))
+	footer = binary.LittleEndian.AppendUint64(footer, uint64(len(index)))
+	footer = binary.LittleEndian.AppendUint32(footer, crc32.ChecksumIEEE(index))
+	footer = binary.LittleEndian.AppendUint16(footer, version)
+	footer = append(footer, magic...)
+
+	if _, err := w.w.Write(index); err != nil {
+		return fmt.Errorf("Write: %w", err)
+	}
+
+	if _, err := w.w.Write(footer); err != nil {
+		return fmt.Errorf("Write: %w", err)
+	}
+
+	if err := w.w.Flush(); err != nil {
+		return fmt.Errorf("Flush: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,288 @@
+package table
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// BlockCache is an LRU cache of parsed rows between two index entries,
+// shared by tables and bounded by the approximate size of the cached rows.
+type BlockCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	lru      *list.List // front is the most recently used
+	blocks   map[blockKey]*list.Element
+
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+type blockKey struct {
+	table  uint64
+	offset int64
+}
+
+type cachedBlock struct {
+	key  blockKey
+	rows []row
+	size int64
+}
+
+type row struct {
+	key    string
+	values []string
+}
+
+type CacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Blocks    int
+	Bytes     int64
+}
+
+func NewBlockCache(maxBytes int64) *BlockCache {
+	return &BlockCache{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		blocks:   make(map[blockKey]*list.Element),
+	}
+}
+
+func (c *BlockCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Blocks:    len(c.blocks),
+		Bytes:     c.bytes,
+	}
+}
+
+func (c *BlockCache) get(key blockKey) ([]row, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.blocks[key]
+	if !ok {
+		c.misses++
+
+		return nil, false
+	}
+
+	c.hits++
+	c.lru.MoveToFront(elem)
+
+	return elem.Value.(*cachedBlock).rows, true //nolint:forcetypeassert
+}
+
+func (c *BlockCache) add(key blockKey, rows []row) {
+	size := rowsSize(rows)
+	if size > c.maxBytes {
+		// never fits
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.blocks[key]; ok {
+		// added by a concurrent reader
+		return
+	}
+
+	for c.bytes+size > c.maxBytes {
+		c.removeElement(c.lru.Back())
+		c.evictions++
+	}
+
+	c.blocks[key] = c.lru.PushFront(&cachedBlock{key, rows, size})
+	c.bytes += size
+}
+
+// removeTable removes all blocks of the table, e.g. when the table is closed.
+func (c *BlockCache) removeTable(table uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, elem := range c.blocks {
+		if key.table == table {
+			c.removeElement(elem)
+		}
+	}
+}
+
+func (c *BlockCache) removeElement(elem *list.Element) {
+	block := c.lru.Remove(elem).(*cachedBlock) //nolint:forcetypeassert
+	delete(c.blocks, block.key)
+	c.bytes -= block.size
+}
+
+// rowsSize estimates the memory used by rows, including string and slice headers.
+func rowsSize(rows []row) int64 {
+	const (
+		stringSize = int64(unsafe.Sizeof(""))
+		sliceSize  = int64(unsafe.Sizeof([]string{}))
+	)
+
+	size := int64(len(rows)) * (stringSize + sliceSize)
+
+	for _, r := range rows {
+		size += int64(len(r.key)) + int64(len(r.values))*stringSize
+
+		for _, v := range r.values {
+			size += int64(len(v))
+		}
+	}
+
+	return size
+}
+
+// searchBlock returns the index of the block which may contain key, or -1 if key is out of range.
+// Block i has rows from index[i] up to index[i+1].
+func (t *Table) searchBlock(key string) int {
+	if key < t.index[0].key || key > t.index[len(t.index)-1].key {
+		return -1
+	}
+
+	idx := sort.Search(len(t.index), func(i int) bool {
+		return t.index[i].key >= key
+	})
+
+	if t.index[idx].key == key {
+		return idx
+	}
+
+	return idx - 1
+}
+
+// blockRows returns parsed rows of the block, reading them on cache miss.
+func (t *Table) blockRows(block int) ([]row, error) {
+	offset := t.index[block].offset
+	key := blockKey{t.id, offset}
+
+	if rows, ok := t.cache.get(key); ok {
+		return rows, nil
+	}
+
+	limit := t.size
+	if block+1 < len(t.index) {
+		limit = t.index[block+1].offset
+	}
+
+	// rows are kept in the cache, so do not reuse records
+	reader := t.newRowReader(t.section(offset, limit), false)
+	rows := make([]row, 0, t.maxBlockRows)
+
+	for {
+		cols, err := reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return nil, fmt.Errorf("reader.Read: %w", err)
+		}
+
+		key, values, err := t.splitRow(cols, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		rows = append(rows, row{key, values})
+	}
+
+	t.cache.add(key, rows)
+
+	return rows, nil
+}
+
+// lookupCached returns the values for key from the cached block.
+// The returned slice is shared with the cache and must not be modified.
+func (t *Table) lookupCached(key string) ([]string, error) {
+	if !t.mayContain(key) {
+		return nil, nil
+	}
+
+	block := t.searchBlock(key)
+	if block == -1 {
+		return nil, nil
+	}
+
+	rows, err := t.blockRows(block)
+	if err != nil {
+		return nil, err
+	}
+
+	i, j := searchRows(rows, key)
+	if i == j {
+		return nil, nil
+	}
+
+	if t.opts.Duplicates == DuplicateKeepLast {
+		return rows[j-1].values, nil
+	}
+
+	return rows[i].values, nil
+}
+
+// searchRows returns the range [i, j) of rows with key in the sorted rows.
+func searchRows(rows []row, key string) (int, int) {
+	i := sort.Search(len(rows), func(i int) bool {
+		return rows[i].key >= key
+	})
+
+	j := i
+	for j < len(rows) && rows[j].key == key {
+		j++
+	}
+
+	return i, j
+}
+
+func (t *Table) getCached(key string, p *projection) (Result, error) {
+	profile := Profile{}
+	time1 := time.Now()
+
+	value, err := t.lookupCached(key)
+	if err != nil {
+		return Result{}, fmt.Errorf("lookupCached: %w", err)
+	}
+
+	profile.Scan = time.Since(time1)
+
+	return t.result(value, profile, p)
+}
+
+func (t *Table) bulkIterCached(keys []string) *Iterator {
+	i := 0
+
+	return t.newIterator(func(it *Iterator) (bool, error) {
+		if i == len(keys) {
+			return false, nil
+		}
+
+		values, err := t.lookupCached(keys[i])
+		if err != nil {
+			return false, fmt.Errorf("lookupCached: %w", err)
+		}
+
+		it.key = keys[i]
+		// values are shared with the cached block, so callers must not be able to modify them
+		it.values = copyValues(values)
+		i++
+
+		return true, nil
+	})
+}
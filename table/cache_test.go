@@ -0,0 +1,79 @@
+package table_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+func TestBlockCacheEviction(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, testFile, "a,1", "b,2", "c,3")
+
+	// measure the size of one block
+	cache := yuccaTable.NewBlockCache(1 << 20)
+
+	table, err := yuccaTable.BuildTable(testFile, &recordLogger{}, yuccaTable.BuildOptions{BlockCache: cache})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testGet(t, table, "a", []string{"1"})
+
+	blockSize := cache.Stats().Bytes
+
+	table.Close()
+
+	// index blocks are [a, b] and [c], so the cache can hold only one of them
+	cache = yuccaTable.NewBlockCache(blockSize)
+
+	table, err = yuccaTable.BuildTable(testFile, &recordLogger{}, yuccaTable.BuildOptions{BlockCache: cache})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	testGet(t, table, "a", []string{"1"})
+	testGet(t, table, "b", []string{"2"})
+	testGet(t, table, "c", []string{"3"})
+	testGet(t, table, "a", []string{"1"})
+
+	stats := cache.Stats()
+	want := yuccaTable.CacheStats{Hits: 1, Misses: 3, Evictions: 2, Blocks: 1, Bytes: blockSize}
+
+	if stats != want {
+		t.Fatalf("expected %+v, but got %+v", want, stats)
+	}
+}
+
+func TestBlockCacheBulkIterValues(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, testFile, "a,1", "b,2", "c,3")
+
+	table, err := yuccaTable.BuildTable(testFile, &recordLogger{}, yuccaTable.BuildOptions{BlockCache: yuccaTable.NewBlockCache(1 << 20)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	it, err := table.BulkIter([]string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for it.Next() {
+		it.Values()[0] = "modified"
+	}
+
+	if err := it.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	testGet(t, table, "a", []string{"1"})
+	testGet(t, table, "b", []string{"2"})
+}
@@ -0,0 +1,108 @@
+package table
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrDuplicateColumn = errors.New("duplicate column name")
+
+// columnIndex maps column names to positions in values, which exclude the key column.
+type columnIndex map[string]int
+
+// initColumns sets column names from the Columns or Schema option, or from header if neither is set.
+// Tables without names have no column index.
+func (t *Table) initColumns(header []string) error {
+	names := t.opts.Columns
+	if names == nil && t.opts.Schema != nil {
+		names = t.opts.Schema.names()
+	}
+
+	if names == nil {
+		names = header
+	}
+
+	if t.opts.Schema != nil && len(names) != len(t.opts.Schema) {
+		return fmt.Errorf("%d column names, but schema has %d columns", len(names), len(t.opts.Schema))
+	}
+
+	if names == nil {
+		return nil
+	}
+
+	if t.maxKeyColumn >= len(names) {
+		return fmt.Errorf("%w: key column %d, but %d column names", ErrNoKeyColumn, t.maxKeyColumn, len(names))
+	}
+
+	index := make(columnIndex, len(names)-len(t.keyColumns))
+	seen := make(map[string]bool, len(names))
+
+	for i, name := range names {
+		if seen[name] {
+			return fmt.Errorf("%w: %q", ErrDuplicateColumn, name)
+		}
+
+		seen[name] = true
+
+		if !slices.Contains(t.keyColumns, i) {
+			index[name] = len(index)
+		}
+	}
+
+	t.columns = slices.Clone(names)
+	t.columnIndex = index
+
+	return nil
+}
+
+// Columns returns the names of all columns including the key columns in file order,
+// or nil if the table has neither a header nor the Columns option.
+func (t *Table) Columns() []string {
+	return slices.Clone(t.columns)
+}
+
+// result copies values into a Result addressable by column names,
+// keeping only the projected columns if p is not nil.
+func (t *Table) result(values []string, profile Profile, p *projection) (Result, error) {
+	projected, err := p.project(values)
+	if err != nil {
+		return Result{}, fmt.Errorf("project: %w", err)
+	}
+
+	columns := t.columnIndex
+	if p != nil {
+		columns = p.columns
+	}
+
+	return Result{Values: projected, Profile: profile, columns: columns}, nil
+}
+
+// Get returns the value of the column, and false if the key was not found,
+// the column does not exist, or the table has no column names.
+// The key column is not included in values.
+func (r Result) Get(column string) (string, bool) {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.Values) {
+		return "", false
+	}
+
+	return r.Values[i], true
+}
+
+// Map returns values by column names, or nil if the key was not found or the table has no column names.
+func (r Result) Map() map[string]string {
+	if r.Values == nil || r.columns == nil {
+		return nil
+	}
+
+	m := make(map[string]string, len(r.columns))
+
+	for name, i := range r.columns {
+		if i < len(r.Values) {
+			m[name] = r.Values[i]
+		}
+	}
+
+	return m
+}
@@ -0,0 +1,52 @@
+package table
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/yokomotod/yuccadb/bgzf"
+)
+
+// compressedSuffix marks gzip compressed data files, e.g. "data.csv.gz".
+//
+// Compressed files are read by blocks, so that lookups decompress only the blocks they read.
+// Files written by bgzf.Writer have blocks of bgzf.BlockSize, while a file compressed by plain gzip
+// is a single block, which is rewritten to PreparedFile in smaller blocks on load.
+const compressedSuffix = ".gz"
+
+// maxCompressedBlockSize is the largest uncompressed size of blocks which are read as they are.
+const maxCompressedBlockSize = 16 * bgzf.BlockSize
+
+var errLargeBlocks = errors.New("compressed file has too large blocks")
+
+func isCompressed(file string) bool {
+	return strings.HasSuffix(strings.ToLower(file), compressedSuffix)
+}
+
+// maxBlockSize returns the largest uncompressed size of the blocks of size bytes of data.
+func maxBlockSize(blocks []bgzf.Block, size int64) int64 {
+	var maxSize int64
+
+	for i, block := range blocks {
+		limit := size
+		if i+1 < len(blocks) {
+			limit = blocks[i+1].UncompressedOffset
+		}
+
+		maxSize = max(maxSize, limit-block.UncompressedOffset)
+	}
+
+	return maxSize
+}
+
+// dataReader returns a reader of the uncompressed data of file, which is size bytes,
+// from the blocks recorded when the index was built.
+func (t *Table) dataReader(file *os.File, blocks []bgzf.Block, fileSize, size int64) io.ReaderAt {
+	if !t.compressed {
+		return file
+	}
+
+	return bgzf.NewReaderAt(file, blocks, fileSize, size)
+}
@@ -0,0 +1,148 @@
+package table_test
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yokomotod/yuccadb/bgzf"
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+func writeCompressed(t *testing.T, file string, n int) {
+	t.Helper()
+
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := bgzf.NewWriter(f)
+	for i := range n {
+		fmt.Fprintf(w, "key%06d,value%d\n", i, i)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompressed(t *testing.T) {
+	t.Parallel()
+
+	const n = 20_000
+
+	testFile := filepath.Join(t.TempDir(), "test.csv.gz")
+	writeCompressed(t, testFile, n)
+
+	for _, cache := range []*yuccaTable.BlockCache{nil, yuccaTable.NewBlockCache(1 << 20)} {
+		opts := yuccaTable.BuildOptions{BlockCache: cache, ReadMode: yuccaTable.ReadModeMmap, Verify: yuccaTable.VerifyChecksum}
+
+		// build, then load from the index file
+		for range 2 {
+			table, err := yuccaTable.LoadTable(testFile, &recordLogger{}, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer table.Close()
+
+			if table.ReadMode() != yuccaTable.ReadModeFile {
+				t.Fatalf("compressed files should not be mapped, but read mode is %v", table.ReadMode())
+			}
+
+			for _, i := range []int{0, 1, 4_321, 12_345, n - 1} {
+				testGet(t, table, fmt.Sprintf("key%06d", i), []string{fmt.Sprintf("value%d", i)})
+			}
+
+			testGet(t, table, "key", nil)
+			testGet(t, table, "key1000000", nil)
+
+			scan, err := table.Scan("key009998", "key010002", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(scan.Keys, []string{"key009998", "key009999", "key010000", "key010001"}) {
+				t.Fatalf("unexpected scan keys: %v", scan.Keys)
+			}
+		}
+	}
+}
+
+func TestCompressedPlainGzip(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.jsonl.gz")
+	writeGzip(t, testFile, `{"key": "a", "v": 1}`+"\n"+`{"key": "b", "v": 2}`+"\n")
+
+	table, err := yuccaTable.BuildTable(testFile, &recordLogger{}, yuccaTable.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	testGet(t, table, "b", []string{`{"key": "b", "v": 2}`})
+
+	// a large single block is rewritten in smaller blocks, so that lookups do not decompress the whole file
+	const n = 100_000
+
+	largeFile := filepath.Join(t.TempDir(), "large.csv.gz")
+
+	var data strings.Builder
+	for i := range n {
+		fmt.Fprintf(&data, "key%06d,value%d\n", i, i)
+	}
+
+	writeGzip(t, largeFile, data.String())
+
+	for _, wantLog := range []string{"too large blocks", "from index file"} {
+		logger := &recordLogger{}
+
+		large, err := yuccaTable.LoadTable(largeFile, logger, yuccaTable.BuildOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer large.Close()
+
+		if !logger.contains(wantLog) {
+			t.Fatalf("expected log %q, but got logs %q", wantLog, logger.messages)
+		}
+
+		testGet(t, large, "key000000", []string{"value0"})
+		testGet(t, large, fmt.Sprintf("key%06d", n-1), []string{fmt.Sprintf("value%d", n-1)})
+	}
+
+	if _, err := os.Stat(yuccaTable.PreparedFile(largeFile)); err != nil {
+		t.Fatalf("expected the prepared file: %v", err)
+	}
+
+	_, err = yuccaTable.BuildTable(filepath.Join(t.TempDir(), "test.sst.gz"), &recordLogger{}, yuccaTable.BuildOptions{})
+	if !errors.Is(err, yuccaTable.ErrUnsupportedOption) {
+		t.Fatalf("expected ErrUnsupportedOption for compressed sstable, but got %v", err)
+	}
+}
+
+func writeGzip(t *testing.T, file, data string) {
+	t.Helper()
+
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := gzip.NewWriter(f)
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
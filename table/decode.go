@@ -0,0 +1,227 @@
+package table
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrUnknownColumn = errors.New("unknown column")
+	// ErrNullValue is returned by typed accessors for empty values.
+	ErrNullValue = errors.New("null value")
+)
+
+// value returns the non-empty value of the column.
+func (r Result) value(column string) (string, error) {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.Values) {
+		return "", fmt.Errorf("%w: %q", ErrUnknownColumn, column)
+	}
+
+	if r.Values[i] == "" {
+		return "", fmt.Errorf("%w: %q", ErrNullValue, column)
+	}
+
+	return r.Values[i], nil
+}
+
+func (r Result) Int64(column string) (int64, error) {
+	value, err := r.value(column)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("column %q: %w", column, err)
+	}
+
+	return v, nil
+}
+
+func (r Result) Float64(column string) (float64, error) {
+	value, err := r.value(column)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("column %q: %w", column, err)
+	}
+
+	return v, nil
+}
+
+func (r Result) Bool(column string) (bool, error) {
+	value, err := r.value(column)
+	if err != nil {
+		return false, err
+	}
+
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("column %q: %w", column, err)
+	}
+
+	return v, nil
+}
+
+// Time parses the value in one of the formats accepted by TypeTimestamp.
+func (r Result) Time(column string) (time.Time, error) {
+	value, err := r.value(column)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	v, err := parseTimestamp(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("column %q: %w", column, err)
+	}
+
+	return v, nil
+}
+
+// JSON unmarshals the value into v.
+func (r Result) JSON(column string, v any) error {
+	value, err := r.value(column)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		return fmt.Errorf("column %q: %w", column, err)
+	}
+
+	return nil
+}
+
+var (
+	errNotStructPointer = errors.New("destination must be a non-nil pointer to a struct")
+	timeType            = reflect.TypeFor[time.Time]() //nolint:gochecknoglobals
+)
+
+// ScanInto sets fields of the struct pointed by dst from values.
+// Fields are matched by the `yucca:"column"` tag, or by the field name without the tag,
+// and fields tagged `yucca:"-"` are skipped. It is an error if a tagged column does not exist.
+//
+// Strings, integers, floats, bools and time.Time fields are parsed from the value,
+// and other types are unmarshaled as JSON. Null values leave fields zero, or nil for pointers.
+// The key column is not a value, so it cannot be scanned.
+func (r Result) ScanInto(dst any) error {
+	ptr := reflect.ValueOf(dst)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() || ptr.Elem().Kind() != reflect.Struct {
+		return errNotStructPointer
+	}
+
+	structValue := ptr.Elem()
+	structType := structValue.Type()
+
+	for i := range structType.NumField() {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		column, tagged := field.Tag.Lookup("yucca")
+		if column == "-" {
+			continue
+		}
+
+		if !tagged {
+			column = field.Name
+		}
+
+		idx, ok := r.columns[column]
+		if !ok || idx >= len(r.Values) {
+			if tagged {
+				return fmt.Errorf("%w: %q", ErrUnknownColumn, column)
+			}
+
+			continue
+		}
+
+		if err := setField(structValue.Field(i), r.Values[idx]); err != nil {
+			return fmt.Errorf("column %q: %w", column, err)
+		}
+	}
+
+	return nil
+}
+
+var errUnsupportedField = errors.New("unsupported field type")
+
+func setField(field reflect.Value, value string) error {
+	if value == "" && field.Kind() != reflect.String {
+		field.SetZero()
+
+		return nil
+	}
+
+	if field.Kind() == reflect.Pointer {
+		elem := reflect.New(field.Type().Elem())
+		if err := setField(elem.Elem(), value); err != nil {
+			return err
+		}
+
+		field.Set(elem)
+
+		return nil
+	}
+
+	if field.Type() == timeType {
+		t, err := parseTimestamp(value)
+		if err != nil {
+			return err
+		}
+
+		field.Set(reflect.ValueOf(t))
+
+		return nil
+	}
+
+	switch field.Kind() { //nolint:exhaustive
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("strconv.ParseInt: %w", err)
+		}
+
+		field.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("strconv.ParseUint: %w", err)
+		}
+
+		field.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("strconv.ParseFloat: %w", err)
+		}
+
+		field.SetFloat(v)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("strconv.ParseBool: %w", err)
+		}
+
+		field.SetBool(v)
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Interface:
+		if err := json.Unmarshal([]byte(value), field.Addr().Interface()); err != nil {
+			return fmt.Errorf("json.Unmarshal: %w", err)
+		}
+	default:
+		return fmt.Errorf("%w: %s", errUnsupportedField, field.Type())
+	}
+
+	return nil
+}
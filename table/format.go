@@ -0,0 +1,163 @@
+package table
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yokomotod/yuccadb/logger"
+)
+
+// TableReader is a loaded table of any format, which YuccaDB serves.
+// *Table implements it for the built-in formats.
+//
+// Implementations may also implement other methods of *Table with the same signatures,
+// e.g. GetColumns, ReverseScan or Columns, to support the corresponding YuccaDB methods.
+type TableReader interface {
+	Get(key string) (Result, error)
+	BulkGet(keys []string) (BulkResult, error)
+	Scan(start, end string, limit int) (ScanResult, error)
+	Stats() Stats
+	// Close releases the resources of the table. It does not remove the files.
+	Close() error
+	// Timestamp returns when the table was loaded.
+	Timestamp() time.Time
+	// File returns the data file, which YuccaDB removes with the table.
+	File() string
+}
+
+// OpenFunc opens the data file of a format as a table.
+// The Format of opts is already resolved, so the same function can serve several formats.
+type OpenFunc func(file string, logger logger.Logger, opts BuildOptions) (TableReader, error)
+
+type registeredFormat struct {
+	name       string
+	extensions []string
+	open       OpenFunc
+}
+
+//nolint:gochecknoglobals
+var (
+	formatsMu sync.RWMutex
+	// formats by Format, starting with the built-in ones, which are opened by LoadTable
+	formats = []registeredFormat{
+		FormatAuto:    {name: "auto"},
+		FormatCSV:     {name: "csv"},
+		FormatJSONL:   {name: "jsonl", extensions: []string{".jsonl", ".ndjson"}},
+		FormatSSTable: {name: "sstable", extensions: []string{".sst"}},
+		FormatParquet: {name: "parquet", extensions: []string{".parquet"}},
+	}
+)
+
+// RegisterFormat registers a format of data files opened by open, and returns the Format to select it.
+// FormatAuto also selects it for files with any of the extensions, e.g. ".avro",
+// which take precedence over the extensions of the formats registered before.
+// It is usually called from init functions, and panics if the name is already registered.
+func RegisterFormat(name string, extensions []string, open OpenFunc) Format {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	for _, f := range formats {
+		if f.name == name {
+			panic(fmt.Sprintf("table: format %q is already registered", name))
+		}
+	}
+
+	lower := make([]string, len(extensions))
+	for i, ext := range extensions {
+		lower[i] = strings.ToLower(ext)
+	}
+
+	formats = append(formats, registeredFormat{name, lower, open})
+
+	return Format(len(formats) - 1)
+}
+
+// ParseFormat returns the format registered by the name, e.g. "csv", and false if there is none.
+func ParseFormat(name string) (Format, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	for i, f := range formats {
+		if f.name == name {
+			return Format(i), true
+		}
+	}
+
+	return 0, false
+}
+
+func lookupFormat(format Format) (registeredFormat, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	if format < 0 || int(format) >= len(formats) {
+		return registeredFormat{}, false
+	}
+
+	return formats[format], true
+}
+
+// formatByExtension returns the latest registered format with the extension, or FormatCSV if none has it.
+func formatByExtension(ext string) Format {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	for i := len(formats) - 1; i >= 0; i-- {
+		if slices.Contains(formats[i].extensions, ext) {
+			return Format(i)
+		}
+	}
+
+	return FormatCSV
+}
+
+// OpenTable opens the data file as a table of the format selected by opts,
+// with LoadTable for the built-in formats and the registered OpenFunc for the others.
+func OpenTable(file string, logger logger.Logger, opts BuildOptions) (TableReader, error) {
+	opts.Format = resolveFormat(file, opts.Format)
+
+	if opts.Format.builtin() {
+		table, err := LoadTable(file, logger, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		return table, nil
+	}
+
+	f, ok := lookupFormat(opts.Format)
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownFormat, opts.Format)
+	}
+
+	return f.open(file, logger, opts) //nolint:wrapcheck
+}
+
+// Stats describes a loaded table.
+type Stats struct {
+	Format string
+	// Rows is the number of rows including duplicate keys.
+	Rows int64
+	// Size is the uncompressed size of the data.
+	Size int64
+	// FileSize is the size of the file read on lookups.
+	FileSize int64
+	// IndexEntries is the number of sparse index entries kept in memory.
+	IndexEntries int
+	// Fields is the number of fields of every row including the key, or 0 if rows may have any number of fields.
+	Fields int
+}
+
+func (t *Table) Stats() Stats {
+	return Stats{
+		Format:       t.opts.Format.String(),
+		Rows:         t.count,
+		Size:         t.size,
+		FileSize:     t.fileSize,
+		IndexEntries: len(t.index),
+		Fields:       t.fields,
+	}
+}
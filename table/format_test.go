@@ -0,0 +1,129 @@
+package table_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yokomotod/yuccadb/logger"
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+// kvTable is a table of "key=value" lines, to test registered formats.
+type kvTable struct {
+	file   string
+	values map[string]string
+}
+
+func openKV(file string, _ logger.Logger, _ yuccaTable.BuildOptions) (yuccaTable.TableReader, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	values := make(map[string]string)
+
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		values[key] = value
+	}
+
+	return &kvTable{file: file, values: values}, nil
+}
+
+func (t *kvTable) Get(key string) (yuccaTable.Result, error) {
+	value, ok := t.values[key]
+	if !ok {
+		return yuccaTable.Result{}, nil
+	}
+
+	return yuccaTable.Result{Values: []string{value}}, nil
+}
+
+func (t *kvTable) BulkGet(keys []string) (yuccaTable.BulkResult, error) {
+	values := make([][]string, len(keys))
+
+	for i, key := range keys {
+		if value, ok := t.values[key]; ok {
+			values[i] = []string{value}
+		}
+	}
+
+	return yuccaTable.BulkResult{Values: values}, nil
+}
+
+func (t *kvTable) Scan(string, string, int) (yuccaTable.ScanResult, error) {
+	return yuccaTable.ScanResult{}, nil
+}
+
+func (t *kvTable) Stats() yuccaTable.Stats {
+	return yuccaTable.Stats{Format: "kv", Rows: int64(len(t.values))}
+}
+
+func (t *kvTable) Close() error         { return nil }
+func (t *kvTable) Timestamp() time.Time { return time.Time{} }
+func (t *kvTable) File() string         { return t.file }
+
+var formatKV = yuccaTable.RegisterFormat("kv", []string{".KV"}, openKV) //nolint:gochecknoglobals
+
+func TestRegisterFormat(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	kvFile := filepath.Join(dir, "test.kv")
+	csvFile := filepath.Join(dir, "test.csv")
+
+	if err := os.WriteFile(kvFile, []byte("a=1\nb=2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	writeCsv(t, csvFile, "a,1", "b,2")
+
+	if format, ok := yuccaTable.ParseFormat("kv"); !ok || format != formatKV || format.String() != "kv" {
+		t.Fatalf("unexpected ParseFormat: %v, %v", format, ok)
+	}
+
+	// selected by the extension or the format
+	for _, opts := range []yuccaTable.BuildOptions{{}, {Format: formatKV}} {
+		table, err := yuccaTable.OpenTable(kvFile, &recordLogger{}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := table.(*kvTable); !ok {
+			t.Fatalf("expected kvTable, but got %T", table)
+		}
+
+		res, err := table.Get("b")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(res.Values, []string{"2"}) {
+			t.Fatalf("unexpected values: %v", res.Values)
+		}
+	}
+
+	table, err := yuccaTable.OpenTable(csvFile, &recordLogger{}, yuccaTable.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	want := yuccaTable.Stats{Format: "csv", Rows: 2, Size: 8, FileSize: 8, IndexEntries: 2, Fields: 2}
+	if !reflect.DeepEqual(table.Stats(), want) {
+		t.Fatalf("expected stats %+v, but got %+v", want, table.Stats())
+	}
+
+	if _, err := yuccaTable.LoadTable(kvFile, &recordLogger{}, yuccaTable.BuildOptions{}); !errors.Is(err, yuccaTable.ErrUnknownFormat) {
+		t.Fatalf("expected ErrUnknownFormat, but got %v", err)
+	}
+
+	if _, err := yuccaTable.OpenTable(csvFile, &recordLogger{}, yuccaTable.BuildOptions{Format: 100}); !errors.Is(err, yuccaTable.ErrUnknownFormat) {
+		t.Fatalf("expected ErrUnknownFormat, but got %v", err)
+	}
+}
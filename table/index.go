@@ -0,0 +1,496 @@
+package table
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/yokomotod/yuccadb/bgzf"
+	"github.com/yokomotod/yuccadb/internals/bloom"
+	"github.com/yokomotod/yuccadb/internals/humanize"
+)
+
+// index file layout (all integers are little endian or varint):
+//
+//	magic "YIDX" | version uint16 | payload | crc32(magic..payload) uint32
+//
+// payload:
+//
+//	indexInterval | indexBytes | maxBlockRows | fields | count | size | file size | modTime (unix nano) | checksum uint32 |
+//	source | format | entries | blocks | bloom
+//	source: file size, modTime (unix nano) and checksum uint32 of the data file which the file was prepared from,
+//	all 0 unless the file is a prepared file
+//	format: length, then options which change the index (see appendFormat)
+//	entries: length, then (key length, key, offset) for each entry
+//	blocks: length, then (compressed offset, uncompressed offset) for each block of compressed files
+//	bloom: false positive rate float64, then length and marshaled filter (length 0 if disabled)
+const (
+	indexFileMagic   = "YIDX"
+	indexFileVersion = 1
+	indexFileSuffix  = ".idx"
+)
+
+var errIndexMismatch = errors.New("index file does not match")
+
+// IndexFile returns the path of the index file written next to csvFile.
+func IndexFile(csvFile string) string {
+	return csvFile + indexFileSuffix
+}
+
+func (t *Table) writeIndex() error {
+	buf := make([]byte, 0, len(t.index)*32)
+	buf = append(buf, indexFileMagic...)
+	buf = binary.LittleEndian.AppendUint16(buf, indexFileVersion)
+	buf = binary.AppendVarint(buf, t.indexInterval)
+	buf = binary.AppendVarint(buf, t.opts.IndexBytes)
+	buf = binary.AppendVarint(buf, t.maxBlockRows)
+	buf = binary.AppendVarint(buf, int64(t.fields))
+	buf = binary.AppendVarint(buf, t.count)
+	buf = binary.AppendVarint(buf, t.size)
+	buf = binary.AppendVarint(buf, t.fileSize)
+	buf = binary.AppendVarint(buf, t.modTime.UnixNano())
+	buf = binary.LittleEndian.AppendUint32(buf, t.checksum)
+	buf = binary.AppendVarint(buf, t.source.size)
+	buf = binary.AppendVarint(buf, unixNano(t.source.modTime))
+	buf = binary.LittleEndian.AppendUint32(buf, t.source.checksum)
+	format := t.appendFormat(nil)
+	buf = binary.AppendUvarint(buf, uint64(len(format)))
+	buf = append(buf, format...)
+	buf = binary.AppendUvarint(buf, uint64(len(t.index)))
+
+	for _, entry := range t.index {
+		buf = binary.AppendUvarint(buf, uint64(len(entry.key)))
+		buf = append(buf, entry.key...)
+		buf = binary.AppendVarint(buf, entry.offset)
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(t.blocks)))
+	for _, block := range t.blocks {
+		buf = binary.AppendVarint(buf, block.CompressedOffset)
+		buf = binary.AppendVarint(buf, block.UncompressedOffset)
+	}
+
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(t.opts.BloomFalsePositiveRate))
+
+	var bloomData []byte
+
+	if t.bloom != nil {
+		var err error
+
+		bloomData, err = t.bloom.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("bloom.MarshalBinary: %w", err)
+		}
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(bloomData)))
+	buf = append(buf, bloomData...)
+
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+
+	// write to a temporary file and rename so that readers never see a partial index.
+	// The index is of the file read, which is the prepared file of prepared tables.
+	indexFile := IndexFile(t.handle.Name())
+	tmpFile := indexFile + ".tmp"
+
+	if err := os.WriteFile(tmpFile, buf, 0o600); err != nil {
+		return fmt.Errorf("os.WriteFile(%q): %w", tmpFile, err)
+	}
+
+	if err := os.Rename(tmpFile, indexFile); err != nil {
+		return fmt.Errorf("os.Rename(%q, %q): %w", tmpFile, indexFile, err)
+	}
+
+	return nil
+}
+
+// appendFormat appends the options which change how the index is built.
+func (t *Table) appendFormat(buf []byte) []byte {
+	flag := func(b bool) byte {
+		if b {
+			return 1
+		}
+
+		return 0
+	}
+
+	buf = append(buf, byte(t.opts.Format))
+	buf = binary.AppendUvarint(buf, uint64(len(t.keyField())))
+	buf = append(buf, t.keyField()...)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(t.opts.Delimiter))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(t.opts.Comment))
+	buf = append(buf, flag(t.opts.LazyQuotes), flag(t.opts.TrimLeadingSpace))
+
+	buf = binary.AppendUvarint(buf, uint64(len(t.keyColumns)))
+	for _, keyColumn := range t.keyColumns {
+		buf = binary.AppendUvarint(buf, uint64(keyColumn))
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(t.keySeparator)))
+	buf = append(buf, t.keySeparator...)
+
+	buf = append(buf, flag(t.opts.Header), byte(t.opts.Duplicates))
+
+	// rows were validated against the schema when the index was built
+	buf = binary.AppendUvarint(buf, uint64(len(t.opts.Schema)))
+	for _, c := range t.opts.Schema {
+		buf = binary.AppendUvarint(buf, uint64(len(c.Name)))
+		buf = append(buf, c.Name...)
+		buf = binary.AppendUvarint(buf, uint64(c.Type))
+	}
+
+	return buf
+}
+
+// unixNano returns the Unix time of tm, or 0 for the zero time.
+func unixNano(tm time.Time) int64 {
+	if tm.IsZero() {
+		return 0
+	}
+
+	return tm.UnixNano()
+}
+
+// loadIndex reads the index file of csvFile, or of its prepared file if the options may prepare it and it has one.
+func (t *Table) loadIndex(csvFile string) error {
+	dataFile, source := csvFile, ""
+
+	if t.mayPrepare() {
+		if _, err := os.Stat(IndexFile(PreparedFile(csvFile))); err == nil {
+			dataFile, source = PreparedFile(csvFile), csvFile
+		}
+	}
+
+	file, err := os.Open(dataFile)
+	if err != nil {
+		return fmt.Errorf("os.Open(%q): %w", dataFile, err)
+	}
+
+	if err := t.readIndex(file, source); err != nil {
+		file.Close()
+
+		return err
+	}
+
+	return nil
+}
+
+// readIndex reads the index file of file and keeps file open for reads.
+// If source is not empty, file is the prepared file of source, and the index must have been built from
+// the current version of source.
+func (t *Table) readIndex(file *os.File, source string) error {
+	time0 := time.Now()
+
+	csvFile := file.Name()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("file.Stat: %w", err)
+	}
+
+	indexFile := IndexFile(csvFile)
+
+	buf, err := os.ReadFile(indexFile)
+	if err != nil {
+		return fmt.Errorf("os.ReadFile(%q): %w", indexFile, err)
+	}
+
+	dec, err := newIndexDecoder(buf)
+	if err != nil {
+		return err
+	}
+
+	indexInterval := dec.varint()
+	indexBytes := dec.varint()
+	maxBlockRows := dec.varint()
+	fields := dec.varint()
+	count := dec.varint()
+	size := dec.varint()
+	fileSize := dec.varint()
+	modTime := dec.varint()
+	checksum := dec.uint32()
+	sourceSize := dec.varint()
+	sourceModTime := dec.varint()
+	sourceChecksum := dec.uint32()
+	format := dec.bytes(dec.uvarint())
+
+	if indexInterval != t.indexInterval {
+		return fmt.Errorf("%w: index interval %d, want %d", errIndexMismatch, indexInterval, t.indexInterval)
+	}
+
+	if indexBytes != t.opts.IndexBytes {
+		return fmt.Errorf("%w: index bytes %d, want %d", errIndexMismatch, indexBytes, t.opts.IndexBytes)
+	}
+
+	if string(format) != string(t.appendFormat(nil)) {
+		return fmt.Errorf("%w: format, key columns, header, duplicates or schema option has been changed", errIndexMismatch)
+	}
+
+	// rows were checked against the columns when the index was built
+	if t.opts.Columns != nil && int(fields) != len(t.opts.Columns) {
+		return fmt.Errorf("%w: %d fields, but %d columns", errIndexMismatch, fields, len(t.opts.Columns))
+	}
+
+	if fileSize != stat.Size() || modTime != stat.ModTime().UnixNano() {
+		return fmt.Errorf("%w: data file has been modified", errIndexMismatch)
+	}
+
+	if t.opts.Verify == VerifyChecksum {
+		if err := verifyChecksum(file, fileSize, checksum); err != nil {
+			return err
+		}
+	}
+
+	var sourceStat fileStat
+
+	if source != "" {
+		if sourceStat, err = t.verifySource(source, sourceSize, sourceModTime, sourceChecksum); err != nil {
+			return err
+		}
+	} else if sourceSize != 0 {
+		return fmt.Errorf("%w: index file is of a prepared file", errIndexMismatch)
+	}
+
+	index := make([]indexEntry, dec.uvarint())
+	for i := range index {
+		index[i].key = string(dec.bytes(dec.uvarint()))
+		index[i].offset = dec.varint()
+	}
+
+	var blocks []bgzf.Block
+
+	if n := dec.uvarint(); n > 0 {
+		blocks = make([]bgzf.Block, n)
+		for i := range blocks {
+			blocks[i].CompressedOffset = dec.varint()
+			blocks[i].UncompressedOffset = dec.varint()
+		}
+	}
+
+	bloomFalsePositiveRate := math.Float64frombits(dec.uint64())
+	bloomData := dec.bytes(dec.uvarint())
+
+	if dec.err != nil {
+		return dec.err
+	}
+
+	if len(index) == 0 {
+		return fmt.Errorf("%w: index is empty", errIndexMismatch)
+	}
+
+	if bloomFalsePositiveRate != t.opts.BloomFalsePositiveRate {
+		return fmt.Errorf("%w: bloom filter false positive rate %v, want %v",
+			errIndexMismatch, bloomFalsePositiveRate, t.opts.BloomFalsePositiveRate)
+	}
+
+	var filter *bloom.Filter
+
+	if len(bloomData) > 0 {
+		filter = &bloom.Filter{}
+		if err := filter.UnmarshalBinary(bloomData); err != nil {
+			return fmt.Errorf("bloom.UnmarshalBinary: %w", err)
+		}
+	}
+
+	reader := t.dataReader(file, blocks, fileSize, size)
+
+	var header []string
+
+	if t.opts.Header && t.opts.Columns == nil {
+		// the header is not kept in the index file since it is cheap to read
+		header, err = t.newCSVReader(io.NewSectionReader(reader, 0, size)).Read()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("csv.Reader.Read: %w", err)
+		}
+	}
+
+	if err := t.initColumns(header); err != nil {
+		return err
+	}
+
+	t.file = csvFile
+	if source != "" {
+		t.file = source
+	}
+
+	t.handle = file
+	t.reader = reader
+	t.blocks = blocks
+	t.index = index
+	t.maxBlockRows = maxBlockRows
+	t.fields = int(fields)
+	t.bloom = filter
+	t.count = count
+	t.size = size
+	t.fileSize = fileSize
+	t.modTime = stat.ModTime()
+	t.checksum = checksum
+	t.source = sourceStat
+	t.timestamp = time.Now()
+
+	t.Logger.Infof("Loaded %q with %s items from index file (%v)", csvFile, humanize.Comma(count), t.timestamp.Sub(time0))
+
+	return nil
+}
+
+// fileChecksum reads the whole file and returns its checksum.
+func fileChecksum(file io.ReaderAt, size int64) (uint32, error) {
+	hash := crc32.NewIEEE()
+	if _, err := io.Copy(hash, io.NewSectionReader(file, 0, size)); err != nil {
+		return 0, fmt.Errorf("io.Copy: %w", err)
+	}
+
+	return hash.Sum32(), nil
+}
+
+// verifyChecksum reads the whole file and compares its checksum.
+func verifyChecksum(file io.ReaderAt, size int64, checksum uint32) error {
+	sum, err := fileChecksum(file, size)
+	if err != nil {
+		return err
+	}
+
+	if sum != checksum {
+		return fmt.Errorf("%w: data file checksum mismatch", errIndexMismatch)
+	}
+
+	return nil
+}
+
+// verifySource checks that the source file is the version which the prepared file was prepared from,
+// by the same checks as the data file.
+func (t *Table) verifySource(source string, size, modTime int64, checksum uint32) (fileStat, error) {
+	file, err := os.Open(source)
+	if err != nil {
+		return fileStat{}, fmt.Errorf("os.Open(%q): %w", source, err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fileStat{}, fmt.Errorf("file.Stat: %w", err)
+	}
+
+	if size != stat.Size() || modTime != stat.ModTime().UnixNano() {
+		return fileStat{}, fmt.Errorf("%w: data file has been modified since it was prepared", errIndexMismatch)
+	}
+
+	if t.opts.Verify == VerifyChecksum {
+		if err := verifyChecksum(file, size, checksum); err != nil {
+			return fileStat{}, err
+		}
+	}
+
+	return fileStat{size, stat.ModTime(), checksum}, nil
+}
+
+// indexDecoder reads the index file payload, remembering the first error
+// so that callers can check it once at the end.
+type indexDecoder struct {
+	buf []byte
+	err error
+}
+
+var errIndexCorrupted = errors.New("index file is corrupted")
+
+func newIndexDecoder(buf []byte) (*indexDecoder, error) {
+	const headerSize = len(indexFileMagic) + 2 + 4
+
+	if len(buf) < headerSize || string(buf[:len(indexFileMagic)]) != indexFileMagic {
+		return nil, errIndexCorrupted
+	}
+
+	payload, sum := buf[:len(buf)-4], binary.LittleEndian.Uint32(buf[len(buf)-4:])
+	if crc32.ChecksumIEEE(payload) != sum {
+		return nil, fmt.Errorf("%w: checksum mismatch", errIndexCorrupted)
+	}
+
+	version := binary.LittleEndian.Uint16(payload[len(indexFileMagic):])
+	if version != indexFileVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", errIndexMismatch, version)
+	}
+
+	return &indexDecoder{buf: payload[len(indexFileMagic)+2:]}, nil
+}
+
+func (d *indexDecoder) fail() {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: unexpected end of payload", errIndexCorrupted)
+	}
+
+	d.buf = nil
+}
+
+func (d *indexDecoder) varint() int64 {
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.fail()
+
+		return 0
+	}
+
+	d.buf = d.buf[n:]
+
+	return v
+}
+
+func (d *indexDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.fail()
+
+		return 0
+	}
+
+	d.buf = d.buf[n:]
+
+	return v
+}
+
+func (d *indexDecoder) uint32() uint32 {
+	const size = 4
+
+	if len(d.buf) < size {
+		d.fail()
+
+		return 0
+	}
+
+	v := binary.LittleEndian.Uint32(d.buf)
+	d.buf = d.buf[size:]
+
+	return v
+}
+
+func (d *indexDecoder) uint64() uint64 {
+	const size = 8
+
+	if len(d.buf) < size {
+		d.fail()
+
+		return 0
+	}
+
+	v := binary.LittleEndian.Uint64(d.buf)
+	d.buf = d.buf[size:]
+
+	return v
+}
+
+func (d *indexDecoder) bytes(n uint64) []byte {
+	if uint64(len(d.buf)) < n {
+		d.fail()
+
+		return nil
+	}
+
+	v := d.buf[:n]
+	d.buf = d.buf[n:]
+
+	return v
+}
@@ -0,0 +1,215 @@
+package table_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+type recordLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordLogger) record(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func (l *recordLogger) Tracef(format string, v ...interface{}) { l.record(format, v...) }
+func (l *recordLogger) Debugf(format string, v ...interface{}) { l.record(format, v...) }
+func (l *recordLogger) Infof(format string, v ...interface{})  { l.record(format, v...) }
+
+func (l *recordLogger) contains(substr string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, msg := range l.messages {
+		if strings.Contains(msg, substr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeCsv(t *testing.T, file string, lines ...string) {
+	t.Helper()
+
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testGet(t *testing.T, table *yuccaTable.Table, key string, want []string) {
+	t.Helper()
+
+	res, err := table.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(res.Values, want) {
+		t.Fatalf("expected %v, but got %v", want, res.Values)
+	}
+}
+
+func TestLoadTableIndexFile(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, testFile, "a,1", "b,2", "c,3")
+
+	table, err := yuccaTable.LoadTable(testFile, &recordLogger{}, yuccaTable.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	if _, err := os.Stat(yuccaTable.IndexFile(testFile)); err != nil {
+		t.Fatalf("index file is not written: %v", err)
+	}
+
+	testGet(t, table, "b", []string{"2"})
+
+	logger := &recordLogger{}
+
+	table, err = yuccaTable.LoadTable(testFile, logger, yuccaTable.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	if !logger.contains("from index file") {
+		t.Fatalf("expected to load from index file, but got logs %q", logger.messages)
+	}
+
+	testGet(t, table, "b", []string{"2"})
+	testGet(t, table, "c", []string{"3"})
+}
+
+func TestLoadTableVerifyChecksum(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, testFile, "a,1", "b,2", "c,3")
+
+	table, err := yuccaTable.LoadTable(testFile, &recordLogger{}, yuccaTable.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.Close()
+
+	stat, err := os.Stat(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// same size and modification time, but different content
+	writeCsv(t, testFile, "a,1", "b,2", "c,4")
+
+	if err := os.Chtimes(testFile, stat.ModTime(), stat.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		verify  yuccaTable.VerifyLevel
+		wantLog string
+	}{
+		{yuccaTable.VerifyMetadata, "from index file"},
+		{yuccaTable.VerifyChecksum, "checksum mismatch"},
+		{yuccaTable.VerifyRebuild, "rebuilding"},
+	}
+
+	for _, c := range cases {
+		logger := &recordLogger{}
+
+		table, err := yuccaTable.LoadTable(testFile, logger, yuccaTable.BuildOptions{Verify: c.verify})
+		if err != nil {
+			t.Fatal(err)
+		}
+		table.Close()
+
+		if !logger.contains(c.wantLog) {
+			t.Fatalf("verify %v: expected log %q, but got logs %q", c.verify, c.wantLog, logger.messages)
+		}
+	}
+}
+
+func TestLoadTableRebuild(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		modify  func(t *testing.T, testFile string)
+		wantLog string
+		wantB   string
+	}{
+		{"data file modified", func(t *testing.T, testFile string) {
+			t.Helper()
+
+			writeCsv(t, testFile, "a,1", "b,22", "c,3")
+
+			future := time.Now().Add(time.Hour)
+			if err := os.Chtimes(testFile, future, future); err != nil {
+				t.Fatal(err)
+			}
+		}, "modified", "22"},
+		{"index file corrupted", func(t *testing.T, testFile string) {
+			t.Helper()
+
+			// the data file is unchanged, so only the index checksum can tell
+			indexFile := yuccaTable.IndexFile(testFile)
+
+			buf, err := os.ReadFile(indexFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buf[len(buf)/2] ^= 0xff
+			if err := os.WriteFile(indexFile, buf, 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}, "corrupted", "2"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			testFile := filepath.Join(t.TempDir(), "test.csv")
+			writeCsv(t, testFile, "a,1", "b,2", "c,3")
+
+			table, err := yuccaTable.LoadTable(testFile, &recordLogger{}, yuccaTable.BuildOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			table.Close()
+
+			c.modify(t, testFile)
+
+			logger := &recordLogger{}
+
+			table, err = yuccaTable.LoadTable(testFile, logger, yuccaTable.BuildOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer table.Close()
+
+			if !logger.contains("rebuilding") || !logger.contains(c.wantLog) {
+				t.Fatalf("expected to rebuild index for %q, but got logs %q", c.wantLog, logger.messages)
+			}
+
+			testGet(t, table, "b", []string{c.wantB})
+		})
+	}
+}
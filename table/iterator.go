@@ -0,0 +1,225 @@
+package table
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Iterator streams rows without materializing them.
+// Close must be called after use.
+//
+//	it, err := table.PrefixScan("tenant#user#")
+//	...
+//	defer it.Close()
+//
+//	for it.Next() {
+//		fmt.Println(it.Key(), it.Values())
+//	}
+//
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator struct {
+	table   *Table
+	scanner *rowScanner
+	next    func(it *Iterator) (bool, error)
+	key     string
+	values  []string
+	err     error
+	done    bool
+}
+
+func (t *Table) newIterator(next func(it *Iterator) (bool, error)) *Iterator {
+	return &Iterator{
+		table: t,
+		next:  next,
+	}
+}
+
+// seek moves the iterator to offset and resets the reader.
+func (it *Iterator) seek(offset int64) {
+	it.scanner = it.table.newRowScanner(it.table.section(offset, it.table.size))
+}
+
+// newRangeIterator returns an iterator which skips keys before start
+// and stops at the first key for which inRange returns false.
+// Rows with equal keys are yielded by the duplicate policy.
+func (t *Table) newRangeIterator(start string, inRange func(key string) bool) (*Iterator, error) {
+	offset := t.seekIndex(start)
+
+	var lastKey string
+
+	first := true
+
+	it := t.newIterator(func(it *Iterator) (bool, error) {
+		for {
+			key, values, err := it.scanner.read()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return false, nil
+				}
+
+				return false, err
+			}
+
+			if key < start {
+				continue
+			}
+
+			if !inRange(key) {
+				return false, nil
+			}
+
+			if t.opts.Duplicates == DuplicateKeepFirst && !first && key == lastKey {
+				continue
+			}
+
+			if t.opts.Duplicates == DuplicateKeepLast {
+				values, err = it.scanner.readLast(key, values)
+				if err != nil {
+					return false, err
+				}
+			}
+
+			lastKey = key
+			first = false
+
+			it.key = key
+			it.values = values
+
+			return true, nil
+		}
+	})
+	it.seek(offset)
+
+	return it, nil
+}
+
+// seekIndex returns the offset of the block which may contain the first key >= key.
+func (t *Table) seekIndex(key string) int64 {
+	// begin from the block before the first index key >= key,
+	// since the block may contain keys between its index key and key
+	idx := sort.Search(len(t.index), func(i int) bool {
+		return t.index[i].key >= key
+	})
+	if idx > 0 {
+		idx--
+	}
+
+	return t.index[idx].offset
+}
+
+// Next advances the iterator to the next row, and reports whether there is one.
+func (it *Iterator) Next() bool {
+	if it.done {
+		return false
+	}
+
+	ok, err := it.next(it)
+	if err != nil {
+		it.err = err
+	}
+
+	if !ok {
+		it.done = true
+		it.key = ""
+		it.values = nil
+	}
+
+	return ok
+}
+
+// Key returns the key of the current row.
+func (it *Iterator) Key() string {
+	return it.key
+}
+
+// Values returns the values of the current row.
+// The returned slice is only valid until the next call to Next.
+func (it *Iterator) Values() []string {
+	return it.values
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+func (it *Iterator) Close() error {
+	it.done = true
+
+	return nil
+}
+
+// Iter returns an iterator over rows whose keys are in the half-open range [start, end).
+// Empty end means no upper bound, so Iter("", "") iterates over the whole table.
+func (t *Table) Iter(start, end string) (*Iterator, error) {
+	return t.newRangeIterator(start, func(key string) bool {
+		return end == "" || key < end
+	})
+}
+
+// PrefixScan returns an iterator over rows whose keys start with prefix.
+func (t *Table) PrefixScan(prefix string) (*Iterator, error) {
+	return t.newRangeIterator(prefix, func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+// BulkIter returns an iterator which yields each of the sorted keys in order.
+// Values is nil for keys which do not exist.
+func (t *Table) BulkIter(keys []string) (*Iterator, error) {
+	if !sort.StringsAreSorted(keys) {
+		return nil, ErrKeysNotSorted
+	}
+
+	if t.cache != nil {
+		return t.bulkIterCached(keys), nil
+	}
+
+	var chunks []*bulkSearchChunk
+	if len(keys) > 0 {
+		chunks = t.bulkSearchIndices(keys)
+	}
+
+	chunkIdx, keyIdx := 0, 0
+
+	return t.newIterator(func(it *Iterator) (bool, error) {
+		if chunkIdx == len(chunks) {
+			return false, nil
+		}
+
+		chunk := chunks[chunkIdx]
+
+		if keyIdx == 0 && chunk.offset != -1 {
+			it.seek(chunk.offset)
+		}
+
+		key := chunk.keys[keyIdx]
+
+		var values []string
+
+		if chunk.offset != -1 && t.mayContain(key) {
+			var err error
+
+			values, err = t.scanFile(it.scanner, key)
+			if err != nil {
+				return false, fmt.Errorf("scanFile: %w", err)
+			}
+		}
+
+		keyIdx++
+		if keyIdx == len(chunk.keys) {
+			chunkIdx++
+			keyIdx = 0
+		}
+
+		it.key = key
+		it.values = values
+
+		return true, nil
+	}), nil
+}
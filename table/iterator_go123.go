@@ -0,0 +1,21 @@
+//go:build go1.23
+
+package table
+
+import "iter"
+
+// All returns an iter.Seq2 over the keys and values of the remaining rows.
+// Values are only valid within the loop body, and Err should be checked after the loop.
+//
+//	for key, values := range it.All() {
+//		...
+//	}
+func (it *Iterator) All() iter.Seq2[string, []string] {
+	return func(yield func(string, []string) bool) {
+		for it.Next() {
+			if !yield(it.Key(), it.Values()) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+//go:build go1.23
+
+package table_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+func TestIteratorAll(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, testFile, "a,1", "b,2", "c,3")
+
+	table, err := yuccaTable.BuildTable(testFile, &recordLogger{}, yuccaTable.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	it, err := table.Iter("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	var keys, values []string
+
+	for key, vals := range it.All() {
+		keys = append(keys, key)
+		values = append(values, vals...)
+	}
+
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(keys, []string{"a", "b", "c"}) || !reflect.DeepEqual(values, []string{"1", "2", "3"}) {
+		t.Fatalf("unexpected rows: %v %v", keys, values)
+	}
+}
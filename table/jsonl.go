@@ -0,0 +1,150 @@
+package table
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// jsonlReader reads JSON Lines as rows of the key and the raw JSON object,
+// so that the rest of the table works the same as for CSV.
+type jsonlReader struct {
+	reader      *bufio.Reader
+	keyField    string
+	reuseRecord bool
+	record      []string
+	offset      int64
+	line        int
+}
+
+var (
+	ErrNoKeyField      = errors.New("object has no key field")
+	errInvalidKeyField = errors.New("key field must be a string or a number")
+)
+
+func newJSONLReader(r io.Reader, keyField string, reuseRecord bool) *jsonlReader {
+	return &jsonlReader{
+		reader:      bufio.NewReader(r),
+		keyField:    keyField,
+		reuseRecord: reuseRecord,
+	}
+}
+
+// Read returns the key and the raw object of the next non-empty line.
+func (r *jsonlReader) Read() ([]string, error) {
+	for {
+		line, err := r.reader.ReadString('\n')
+		if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+			return nil, err //nolint:wrapcheck
+		}
+
+		r.offset += int64(len(line))
+		r.line++
+
+		raw := strings.TrimSpace(line)
+		if raw == "" {
+			continue
+		}
+
+		key, err := jsonKey(raw, r.keyField)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", r.line, err)
+		}
+
+		record := r.record
+		if !r.reuseRecord || record == nil {
+			record = make([]string, 2) //nolint:mnd
+		}
+
+		record[0], record[1] = key, raw
+		r.record = record
+
+		return record, nil
+	}
+}
+
+// InputOffset returns the offset of the end of the last read line.
+func (r *jsonlReader) InputOffset() int64 {
+	return r.offset
+}
+
+// FieldPos returns the line of the last read row.
+func (r *jsonlReader) FieldPos(int) (int, int) {
+	return r.line, 1
+}
+
+// jsonKey returns the top-level field of the object as a key,
+// without decoding the other fields.
+func jsonKey(raw, keyField string) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(raw))
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return "", fmt.Errorf("%w: not an object", errInvalidJSON)
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", fmt.Errorf("json.Decoder.Token: %w", err)
+		}
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return "", fmt.Errorf("json.Decoder.Decode: %w", err)
+		}
+
+		if tok != keyField {
+			continue
+		}
+
+		switch {
+		case bytes.HasPrefix(value, []byte(`"`)):
+			var key string
+			if err := json.Unmarshal(value, &key); err != nil {
+				return "", fmt.Errorf("json.Unmarshal: %w", err)
+			}
+
+			return key, nil
+		case len(value) > 0 && (value[0] == '-' || ('0' <= value[0] && value[0] <= '9')):
+			return string(value), nil
+		default:
+			return "", fmt.Errorf("%w: %s", errInvalidKeyField, value)
+		}
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrNoKeyField, keyField)
+}
+
+// jsonFields returns the top-level fields of the object as strings.
+// Strings are unquoted, other values are JSON, and missing fields and nulls are empty.
+func jsonFields(raw string, fields []string) ([]string, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &object); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	values := make([]string, len(fields))
+
+	for i, field := range fields {
+		value, ok := object[field]
+		if !ok || string(value) == "null" {
+			continue
+		}
+
+		if value[0] == '"' {
+			if err := json.Unmarshal(value, &values[i]); err != nil {
+				return nil, fmt.Errorf("json.Unmarshal: %w", err)
+			}
+
+			continue
+		}
+
+		values[i] = string(value)
+	}
+
+	return values, nil
+}
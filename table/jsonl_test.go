@@ -0,0 +1,101 @@
+package table_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+func TestJSONL(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.jsonl")
+	writeCsv(t, testFile,
+		`{"id": "a", "name": "alice", "score": 1}`,
+		``,
+		`{"name": "bob", "id": "b", "tags": ["x"]}`,
+		`{"id": "c", "name": "carol", "score": null}`,
+	)
+
+	rows := map[string]string{
+		"a": `{"id": "a", "name": "alice", "score": 1}`,
+		"b": `{"name": "bob", "id": "b", "tags": ["x"]}`,
+		"c": `{"id": "c", "name": "carol", "score": null}`,
+	}
+
+	for _, cache := range []*yuccaTable.BlockCache{nil, yuccaTable.NewBlockCache(1 << 20)} {
+		opts := yuccaTable.BuildOptions{KeyField: "id", IndexInterval: 2, BlockCache: cache}
+
+		// build, then load from the index file
+		for range 2 {
+			table, err := yuccaTable.LoadTable(testFile, &recordLogger{}, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer table.Close()
+
+			for key, raw := range rows {
+				testGet(t, table, key, []string{raw})
+			}
+
+			testGet(t, table, "bb", nil)
+
+			res, err := table.GetColumns("b", []string{"name", "tags", "score"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(res.Values, []string{"bob", `["x"]`, ""}) {
+				t.Fatalf("unexpected values: %q", res.Values)
+			}
+
+			bulk, err := table.BulkGetColumns([]string{"a", "c"}, []string{"score"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(bulk.Values, [][]string{{"1"}, {""}}) {
+				t.Fatalf("unexpected bulk values: %q", bulk.Values)
+			}
+
+			scan, err := table.Scan("b", "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(scan.Keys, []string{"b", "c"}) {
+				t.Fatalf("unexpected scan keys: %v", scan.Keys)
+			}
+		}
+	}
+}
+
+func TestJSONLErrors(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		line    string
+		opts    yuccaTable.BuildOptions
+		wantErr error
+	}{
+		{"no key field", `{"id": "a"}`, yuccaTable.BuildOptions{}, yuccaTable.ErrNoKeyField},
+		{"header", `{"key": "a"}`, yuccaTable.BuildOptions{Header: true}, yuccaTable.ErrUnsupportedOption},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			testFile := filepath.Join(t.TempDir(), "test.ndjson")
+			writeCsv(t, testFile, c.line)
+
+			if _, err := yuccaTable.BuildTable(testFile, &recordLogger{}, c.opts); !errors.Is(err, c.wantErr) {
+				t.Fatalf("expected %v, but got %v", c.wantErr, err)
+			}
+		})
+	}
+}
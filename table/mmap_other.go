@@ -0,0 +1,18 @@
+//go:build !unix
+
+package table
+
+import (
+	"errors"
+	"os"
+)
+
+var errMmapNotSupported = errors.New("mmap is not supported on this platform")
+
+func mmap(_ *os.File, _ int64) ([]byte, error) {
+	return nil, errMmapNotSupported
+}
+
+func munmap(_ []byte) error {
+	return errMmapNotSupported
+}
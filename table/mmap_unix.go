@@ -0,0 +1,26 @@
+//go:build unix
+
+package table
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func mmap(file *os.File, size int64) ([]byte, error) {
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("syscall.Mmap: %w", err)
+	}
+
+	return data, nil
+}
+
+func munmap(data []byte) error {
+	if err := syscall.Munmap(data); err != nil {
+		return fmt.Errorf("syscall.Munmap: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package table
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// GetAll returns all rows for key in file order for DuplicateMulti tables,
+// and at most one row as Get does for the other duplicate policies.
+func (t *Table) GetAll(key string) ([]Result, error) {
+	if t.opts.Duplicates != DuplicateMulti {
+		res, err := t.get(key, nil)
+		if err != nil || res.Values == nil {
+			return nil, err
+		}
+
+		return []Result{res}, nil
+	}
+
+	if !t.mayContain(key) {
+		return nil, nil
+	}
+
+	if t.cache != nil {
+		return t.getAllCached(key)
+	}
+
+	offset, _ := t.searchIndex(key)
+	if offset == -1 {
+		return nil, nil
+	}
+
+	scanner := t.newRowScanner(t.section(offset, t.size))
+
+	var results []Result
+
+	for {
+		rowKey, values, err := scanner.read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return results, nil
+			}
+
+			return nil, err
+		}
+
+		if rowKey > key {
+			return results, nil
+		}
+
+		if rowKey < key {
+			continue
+		}
+
+		res, err := t.result(values, Profile{}, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, res)
+	}
+}
+
+func (t *Table) getAllCached(key string) ([]Result, error) {
+	block := t.searchBlock(key)
+	if block == -1 {
+		return nil, nil
+	}
+
+	rows, err := t.blockRows(block)
+	if err != nil {
+		return nil, fmt.Errorf("blockRows: %w", err)
+	}
+
+	i, j := searchRows(rows, key)
+
+	results := make([]Result, 0, j-i)
+
+	for _, r := range rows[i:j] {
+		res, err := t.result(r.values, Profile{}, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, res)
+	}
+
+	if len(results) == 0 {
+		return nil, nil
+	}
+
+	return results, nil
+}
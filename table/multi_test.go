@@ -0,0 +1,126 @@
+package table_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+func TestDuplicates(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "test.csv")
+	sstFile := filepath.Join(dir, "test.sst")
+
+	// with the interval 2, "b" rows would be split into blocks if equal keys were not kept together
+	writeCsv(t, csvFile, "a,1", "b,1", "b,2", "b,3", "b,4", "c,1", "d,1", "d,2")
+
+	if err := yuccaTable.ConvertToSSTable(csvFile, sstFile, &recordLogger{}, yuccaTable.BuildOptions{IndexInterval: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		policy   yuccaTable.DuplicatePolicy
+		get      []string
+		getAll   [][]string
+		scanKeys []string
+	}{
+		{
+			yuccaTable.DuplicateKeepFirst,
+			[]string{"1"}, [][]string{{"1"}}, []string{"a", "b", "c", "d"},
+		},
+		{
+			yuccaTable.DuplicateKeepLast,
+			[]string{"4"}, [][]string{{"4"}}, []string{"a", "b", "c", "d"},
+		},
+		{
+			yuccaTable.DuplicateMulti,
+			[]string{"1"}, [][]string{{"1"}, {"2"}, {"3"}, {"4"}}, []string{"a", "b", "b", "b", "b", "c", "d", "d"},
+		},
+	}
+
+	for _, c := range cases {
+		for _, file := range []string{csvFile, sstFile} {
+			for _, cache := range []*yuccaTable.BlockCache{nil, yuccaTable.NewBlockCache(1 << 20)} {
+				opts := yuccaTable.BuildOptions{IndexInterval: 2, Duplicates: c.policy, BlockCache: cache}
+
+				table, err := yuccaTable.BuildTable(file, &recordLogger{}, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer table.Close()
+
+				testGet(t, table, "b", c.get)
+
+				all, err := table.GetAll("b")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var values [][]string
+				for _, res := range all {
+					values = append(values, res.Values)
+				}
+
+				if !reflect.DeepEqual(values, c.getAll) {
+					t.Fatalf("%v %s: expected GetAll %v, but got %v", c.policy, file, c.getAll, values)
+				}
+
+				if all, err := table.GetAll("bb"); err != nil || all != nil {
+					t.Fatalf("expected no rows for a missing key, but got %v, %v", all, err)
+				}
+
+				bulk, err := table.BulkGet([]string{"b", "d"})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(bulk.Values[0], c.get) {
+					t.Fatalf("%v %s: expected BulkGet %v, but got %v", c.policy, file, c.get, bulk.Values[0])
+				}
+
+				scan, err := table.Scan("", "", 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(scan.Keys, c.scanKeys) {
+					t.Fatalf("%v %s: expected scan keys %v, but got %v", c.policy, file, c.scanKeys, scan.Keys)
+				}
+
+				reverse, err := table.ReverseScan("", "", 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// the first "b" row of scans is the last but one row of reverse scans
+				if len(reverse.Keys) != len(c.scanKeys) || reverse.Values[len(reverse.Values)-2][0] != scan.Values[1][0] {
+					t.Fatalf("%v %s: unexpected reverse scan %v %v", c.policy, file, reverse.Keys, reverse.Values)
+				}
+			}
+		}
+	}
+}
+
+func TestDuplicateReject(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "test.csv")
+	writeCsv(t, csvFile, "a,1", "b,1", "b,2")
+
+	opts := yuccaTable.BuildOptions{Duplicates: yuccaTable.DuplicateReject}
+
+	if _, err := yuccaTable.BuildTable(csvFile, &recordLogger{}, opts); !errors.Is(err, yuccaTable.ErrDuplicateKey) {
+		t.Fatalf("expected ErrDuplicateKey, but got %v", err)
+	}
+
+	err := yuccaTable.ConvertToSSTable(csvFile, filepath.Join(dir, "test.sst"), &recordLogger{}, opts)
+	if !errors.Is(err, yuccaTable.ErrDuplicateKey) {
+		t.Fatalf("expected ErrDuplicateKey from ConvertToSSTable, but got %v", err)
+	}
+}
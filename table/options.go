@@ -0,0 +1,221 @@
+package table
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// ReadMode selects how rows are read on lookups.
+type ReadMode int
+
+const (
+	// ReadModeFile reads rows with ReadAt on the shared file handle.
+	ReadModeFile ReadMode = iota
+	// ReadModeMmap reads rows from the memory-mapped data file.
+	// It avoids read syscalls for tables which fit in page cache,
+	// and falls back to ReadModeFile when mapping fails.
+	ReadModeMmap
+)
+
+func (m ReadMode) String() string {
+	switch m {
+	case ReadModeFile:
+		return "file"
+	case ReadModeMmap:
+		return "mmap"
+	default:
+		return "unknown"
+	}
+}
+
+// VerifyLevel selects how much LoadTable checks before trusting an existing index file.
+type VerifyLevel int
+
+const (
+	// VerifyMetadata trusts the index file if the size and modification time of the data file match.
+	VerifyMetadata VerifyLevel = iota
+	// VerifyChecksum additionally reads the whole data file and compares its checksum.
+	// It is still much faster than rebuilding, since rows are not parsed.
+	VerifyChecksum
+	// VerifyRebuild never trusts the index file and always rebuilds it.
+	VerifyRebuild
+)
+
+// DuplicatePolicy selects how rows with equal keys are handled.
+// Rows with equal keys are always kept in the same block, so any policy reads them at once.
+type DuplicatePolicy int
+
+const (
+	// DuplicateKeepFirst returns the first of rows with equal keys from lookups and scans.
+	DuplicateKeepFirst DuplicatePolicy = iota
+	// DuplicateReject fails to build the table if any keys are equal.
+	// For FormatSSTable, it is checked by ConvertToSSTable.
+	DuplicateReject
+	// DuplicateKeepLast returns the last of rows with equal keys from lookups and scans.
+	DuplicateKeepLast
+	// DuplicateMulti keeps all rows with equal keys. Get returns the first row,
+	// GetAll returns all of them, and scans return all of them in file order.
+	DuplicateMulti
+)
+
+func (p DuplicatePolicy) String() string {
+	switch p {
+	case DuplicateKeepFirst:
+		return "keep-first"
+	case DuplicateReject:
+		return "reject"
+	case DuplicateKeepLast:
+		return "keep-last"
+	case DuplicateMulti:
+		return "multi"
+	default:
+		return "unknown"
+	}
+}
+
+// BadRowPolicy selects how rows which cannot be loaded are handled.
+// Bad rows are the rows which Validate reports with problems other than unsorted and duplicate keys.
+type BadRowPolicy int
+
+const (
+	// BadRowsFail fails to build the table at the first row which cannot be loaded.
+	// Rows with invalid UTF-8 are loaded as they are.
+	BadRowsFail BadRowPolicy = iota
+	// BadRowsSkip skips bad rows. If the data file has any, the other rows are copied as they are to PreparedFile,
+	// which the table reads instead, and the data file is left as is.
+	// LoadTable reuses the prepared file while the data file is unchanged.
+	BadRowsSkip
+	// BadRowsQuarantine is like BadRowsSkip, but also appends the skipped rows as they are to QuarantineFile.
+	BadRowsQuarantine
+)
+
+func (p BadRowPolicy) String() string {
+	switch p {
+	case BadRowsFail:
+		return "fail"
+	case BadRowsSkip:
+		return "skip"
+	case BadRowsQuarantine:
+		return "quarantine"
+	default:
+		return "unknown"
+	}
+}
+
+// Format is the format of data files.
+type Format int
+
+const (
+	// FormatAuto selects the format by the file extension, FormatJSONL for ".jsonl" and ".ndjson",
+	// FormatSSTable for ".sst", FormatParquet for ".parquet", the extensions of formats registered
+	// by RegisterFormat, and FormatCSV otherwise.
+	// A ".gz" suffix is ignored, e.g. "data.jsonl.gz" is FormatJSONL.
+	FormatAuto Format = iota
+	FormatCSV
+	// FormatJSONL reads one JSON object per line, keyed by the KeyField of the objects.
+	// Values are the raw objects, and their fields can be selected by GetColumns and so on.
+	FormatJSONL
+	// FormatSSTable reads files written by ConvertToSSTable or sstable.Writer.
+	// The index is read from the file, so no index file is written.
+	FormatSSTable
+	// FormatParquet reads flat Parquet files sorted by the KeyField column, or in any order with Sort.
+	// They are converted to sstable files at ConvertedFile on load, which LoadTable reuses
+	// while the Parquet file is unchanged. Column names are kept with the key column first,
+	// and column types are available from Table.Schema.
+	FormatParquet
+)
+
+// String returns the name of the format, including formats registered by RegisterFormat.
+func (f Format) String() string {
+	registered, ok := lookupFormat(f)
+	if !ok {
+		return "unknown"
+	}
+
+	return registered.name
+}
+
+var (
+	ErrUnknownFormat = errors.New("unknown format")
+	ErrInvalidOption = errors.New("invalid option")
+)
+
+// resolveFormat returns the format of file, selecting it by the extension if format is FormatAuto.
+func resolveFormat(file string, format Format) Format {
+	if format != FormatAuto {
+		return format
+	}
+
+	return formatByExtension(filepath.Ext(strings.TrimSuffix(strings.ToLower(file), compressedSuffix)))
+}
+
+// builtin reports whether the format is read by Table rather than by a registered OpenFunc.
+func (f Format) builtin() bool {
+	return f >= FormatAuto && f <= FormatParquet
+}
+
+// BuildOptions configures how a table is built and read.
+// The zero value is the default.
+type BuildOptions struct {
+	Format Format
+	// KeyField is the field of the key in FormatJSONL objects, or the key column of FormatParquet files.
+	// Default is "key". Key fields must be strings or numbers, and numbers are compared as strings.
+	KeyField string
+	// IndexInterval is the number of rows between sparse index entries. Default is 1,000.
+	// Smaller intervals use more memory and make lookups faster.
+	IndexInterval int64
+	// IndexBytes additionally adds an index entry once a block reaches this many bytes if > 0,
+	// whichever of IndexInterval and IndexBytes comes first.
+	// It bounds the bytes scanned per lookup for files with rows of very different widths.
+	IndexBytes int64
+	// Delimiter is the field delimiter, e.g. '\t' for TSV. Default is ','.
+	Delimiter rune
+	// Comment skips lines beginning with the character if not 0, see csv.Reader.Comment.
+	Comment rune
+	// LazyQuotes allows quotes in unquoted fields and non-doubled quotes in quoted fields.
+	LazyQuotes bool
+	// TrimLeadingSpace ignores leading white space of fields.
+	TrimLeadingSpace bool
+	// KeyColumn is the 0-based column of the key. Values are the other columns.
+	KeyColumn int
+	// KeyColumns makes a composite key from the columns joined with KeySeparator in the order.
+	// It takes precedence over KeyColumn. Rows must be sorted by the joined keys.
+	KeyColumns []int
+	// KeySeparator joins KeyColumns. Default is "\x00",
+	// which keeps joined keys in the same order as their tuples.
+	KeySeparator string
+	// Header skips the first row as a header, and takes column names from it.
+	Header bool
+	// Columns names all columns including the key column in file order.
+	// It takes precedence over the names in the header.
+	Columns []string
+	// Schema types all columns, and every row is validated against it on build.
+	// It also names columns unless Columns is set.
+	Schema Schema
+	// Duplicates selects how rows with equal keys are handled. Default is DuplicateKeepFirst.
+	Duplicates DuplicatePolicy
+	Verify     VerifyLevel
+	ReadMode   ReadMode
+	// BlockCache caches parsed blocks for Get and BulkGet if not nil.
+	BlockCache *BlockCache
+	// BloomFalsePositiveRate builds a bloom filter of keys with the rate if > 0, and must be < 1,
+	// so that Get and BulkGet skip reading the file for most missing keys.
+	BloomFalsePositiveRate float64
+	// Sort sorts the rows by key to PreparedFile when the keys of the data file are not sorted, instead of failing.
+	// The data file is left as is, and LoadTable reuses the prepared file while the data file is unchanged.
+	// It is an external merge sort, so the file may be larger than memory.
+	// Rows are copied as they are, equal keys keep their order, and comment lines move with the following row.
+	// FormatParquet files are sorted while converted to ConvertedFile instead. Not supported for FormatSSTable.
+	Sort bool
+	// SortMemory is the approximate memory used to sort rows in runs. Default is 64 MiB.
+	SortMemory int64
+	// SortDir is the directory of temporary run files. Default is os.TempDir().
+	SortDir string
+	// BadRows selects how rows which cannot be loaded are handled. Default is BadRowsFail.
+	// Only FormatCSV and FormatJSONL support the other policies.
+	BadRows BadRowPolicy
+	// QuarantineFile is the file which BadRowsQuarantine appends bad rows to.
+	// Default is the data file with ".rejected" appended.
+	QuarantineFile string
+}
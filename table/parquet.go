@@ -0,0 +1,349 @@
+package table
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yokomotod/yuccadb/internals/humanize"
+	"github.com/yokomotod/yuccadb/parquet"
+	"github.com/yokomotod/yuccadb/sstable"
+)
+
+const convertedSuffix = ".sst"
+
+// ConvertedFile returns the path of the sstable file converted from parquetFile.
+func ConvertedFile(parquetFile string) string {
+	return parquetFile + convertedSuffix
+}
+
+// loadParquet converts the Parquet file to an sstable file next to it and reads the sstable file.
+// If reuse is true, an existing sstable file is reused when it was converted from the current version
+// of the Parquet file and has the same columns.
+// If the Sort option is set, rows are sorted by key with an external merge sort while converting.
+func (t *Table) loadParquet(file string, reuse bool) error {
+	time0 := time.Now()
+
+	src, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("os.Open(%q): %w", file, err)
+	}
+	defer src.Close()
+
+	stat, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("file.Stat: %w", err)
+	}
+
+	pf, err := parquet.Open(src, stat.Size())
+	if err != nil {
+		return fmt.Errorf("parquet.Open: %w", err)
+	}
+
+	keyColumn := slices.IndexFunc(pf.Columns(), func(c parquet.Column) bool { return c.Name == t.keyField() })
+	if keyColumn < 0 {
+		return fmt.Errorf("%w: no column %q in %q", ErrNoKeyColumn, t.keyField(), file)
+	}
+
+	schema := parquetSchema(pf.Columns(), keyColumn)
+	sstFile := ConvertedFile(file)
+
+	if reuse {
+		err := t.loadConverted(sstFile, schema, src)
+		if err == nil {
+			t.Logger.Infof("Loaded %q with %s items from converted %q (%v)",
+				file, humanize.Comma(t.count), sstFile, time.Since(time0))
+
+			return nil
+		}
+
+		t.Logger.Debugf("Converted file of %q is not usable, converting: %v\n", file, err)
+	}
+
+	checksum, err := fileChecksum(src, stat.Size())
+	if err != nil {
+		return err
+	}
+
+	source := sstable.Source{Size: stat.Size(), ModTime: stat.ModTime().UnixNano(), Checksum: checksum}
+
+	t.keyColumns = []int{keyColumn}
+	t.maxKeyColumn = keyColumn
+
+	if err := t.convertParquet(pf, file, sstFile, source); err != nil {
+		return err
+	}
+
+	if err := t.loadConverted(sstFile, schema, nil); err != nil {
+		return err
+	}
+
+	t.Logger.Infof("Loaded %q with %s items converted to %q (%v)", file, humanize.Comma(t.count), sstFile, time.Since(time0))
+
+	return nil
+}
+
+var errStaleConverted = errors.New("converted file is not of the current parquet file or has different columns")
+
+// loadConverted reads the sstable file if it has the columns of schema,
+// and, if src is not nil, was converted from the current version of src.
+func (t *Table) loadConverted(sstFile string, schema Schema, src *os.File) error {
+	handle, err := os.Open(sstFile)
+	if err != nil {
+		return fmt.Errorf("os.Open(%q): %w", sstFile, err)
+	}
+
+	// the sstable file is keyed by its first column
+	t.keyColumns = []int{0}
+	t.maxKeyColumn = 0
+
+	meta, err := t.readSSTable(handle)
+	if err != nil {
+		handle.Close()
+
+		return err
+	}
+
+	if !slices.Equal(t.columns, schema.names()) {
+		handle.Close()
+
+		return errStaleConverted
+	}
+
+	if src != nil {
+		if err := t.verifyConverted(src, meta.Source); err != nil {
+			handle.Close()
+
+			return err
+		}
+	}
+
+	t.schema = schema
+	t.timestamp = time.Now()
+	// the table is the Parquet file, and the sstable file is a cache of it
+	t.file = strings.TrimSuffix(sstFile, convertedSuffix)
+
+	return nil
+}
+
+// verifyConverted checks that src is the version of the Parquet file which the sstable file
+// was converted from, by the same checks as the data file of an index file.
+func (t *Table) verifyConverted(src *os.File, source sstable.Source) error {
+	stat, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("file.Stat: %w", err)
+	}
+
+	if source.Size != stat.Size() || source.ModTime != stat.ModTime().UnixNano() {
+		return fmt.Errorf("%w: parquet file has been modified since it was converted", errStaleConverted)
+	}
+
+	if t.opts.Verify == VerifyChecksum {
+		if err := verifyChecksum(src, source.Size, source.Checksum); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// convertParquet writes the rows of the Parquet file to sstFile, replacing it atomically.
+func (t *Table) convertParquet(pf *parquet.File, file, sstFile string, source sstable.Source) error {
+	tmpFile := sstFile + ".tmp"
+
+	dst, err := os.Create(tmpFile)
+	if err != nil {
+		return fmt.Errorf("os.Create(%q): %w", tmpFile, err)
+	}
+	defer os.Remove(tmpFile)
+
+	header := make([]string, len(pf.Columns()))
+	for i, c := range pf.Columns() {
+		header[i] = c.Name
+	}
+
+	var reader rowReader = parquetRowReader{pf.NewRowReader()}
+
+	if t.opts.Sort {
+		dir, err := os.MkdirTemp(t.opts.SortDir, "yuccadb-sort-")
+		if err != nil {
+			dst.Close()
+
+			return fmt.Errorf("os.MkdirTemp: %w", err)
+		}
+		defer os.RemoveAll(dir)
+
+		sorted, err := t.sortParquet(reader, file, dir)
+		if err != nil {
+			dst.Close()
+
+			return err
+		}
+		defer sorted.close()
+
+		reader = sorted
+	}
+
+	if _, err := t.convertRows(reader, header, dst, source); err != nil {
+		dst.Close()
+
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("file.Close: %w", err)
+	}
+
+	if err := os.Rename(tmpFile, sstFile); err != nil {
+		return fmt.Errorf("os.Rename(%q, %q): %w", tmpFile, sstFile, err)
+	}
+
+	return nil
+}
+
+// sortParquet writes the rows of reader to sorted runs in dir, and returns a reader of the rows in key order.
+// Each row is kept with its row number, so that errors still locate the row in the Parquet file.
+func (t *Table) sortParquet(reader rowReader, file, dir string) (*sortedRowReader, error) {
+	rows := t.newSorter(file, dir)
+
+	var buf []string
+
+	for {
+		cols, err := reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return nil, fmt.Errorf("reader.Read: %w", err)
+		}
+
+		key, values, err := t.splitRow(cols, buf)
+		if err != nil {
+			return nil, err
+		}
+
+		buf = values
+
+		line, _ := reader.FieldPos(0)
+
+		if err := rows.add(key, append(cols, strconv.Itoa(line))); err != nil {
+			return nil, err
+		}
+	}
+
+	m, err := rows.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return &sortedRowReader{merger: m}, nil
+}
+
+// sortedRowReader is a rowReader of the rows merged by a sorter, positioned by the row numbers kept with them.
+type sortedRowReader struct {
+	*merger
+	line int
+}
+
+func (r *sortedRowReader) Read() ([]string, error) {
+	row, err := r.merger.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	// the key is followed by the columns and the row number
+	cols := row[1 : len(row)-1]
+
+	r.line, err = strconv.Atoi(row[len(row)-1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad row number in sorted run", sstable.ErrCorrupted)
+	}
+
+	return cols, nil
+}
+
+func (r *sortedRowReader) InputOffset() int64 {
+	return 0
+}
+
+func (r *sortedRowReader) FieldPos(int) (int, int) {
+	return r.line, 0
+}
+
+// parquetRowReader is a rowReader of Parquet rows, positioned by 1-based row numbers instead of lines.
+type parquetRowReader struct {
+	*parquet.RowReader
+}
+
+func (r parquetRowReader) InputOffset() int64 {
+	return 0
+}
+
+func (r parquetRowReader) FieldPos(int) (int, int) {
+	return int(r.Row()), 0
+}
+
+// parquetSchema returns the schema of the columns in the order of the converted sstable,
+// the key column followed by the others.
+func parquetSchema(columns []parquet.Column, keyColumn int) Schema {
+	schema := Schema{{columns[keyColumn].Name, parquetColumnType(columns[keyColumn])}}
+
+	for i, c := range columns {
+		if i != keyColumn {
+			schema = append(schema, Column{c.Name, parquetColumnType(c)})
+		}
+	}
+
+	return schema
+}
+
+// parquetColumnType returns the type which accepts the values of the column as formatted by package parquet.
+func parquetColumnType(c parquet.Column) ColumnType {
+	switch c.Kind {
+	case parquet.KindString, parquet.KindTime:
+		return TypeString
+	case parquet.KindJSON:
+		return TypeJSON
+	case parquet.KindDate, parquet.KindTimestamp:
+		return TypeTimestamp
+	case parquet.KindDecimal:
+		return TypeFloat64
+	case parquet.KindUnsigned:
+		if c.Type == parquet.Int64 {
+			// may overflow int64
+			return TypeString
+		}
+
+		return TypeInt64
+	case parquet.KindPlain:
+	}
+
+	switch c.Type { //nolint:exhaustive
+	case parquet.Boolean:
+		return TypeBool
+	case parquet.Int32, parquet.Int64:
+		return TypeInt64
+	case parquet.Int96:
+		return TypeTimestamp
+	case parquet.Float, parquet.Double:
+		return TypeFloat64
+	default:
+		return TypeString
+	}
+}
+
+// Schema returns the types of all columns in the order of Columns,
+// from the Schema option or the Parquet file, or nil if the table is not typed.
+func (t *Table) Schema() Schema {
+	if t.schema != nil {
+		return slices.Clone(t.schema)
+	}
+
+	return slices.Clone(t.opts.Schema)
+}
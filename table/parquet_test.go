@@ -0,0 +1,256 @@
+package table_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yokomotod/yuccadb/internals/parquettest"
+	"github.com/yokomotod/yuccadb/parquet"
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+func writeParquet(t *testing.T, file string, columns []parquet.Column, rows [][]any) {
+	t.Helper()
+
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w, err := parquettest.NewWriter(f, columns, parquettest.WriterOptions{RowGroupRows: 2, Dictionary: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var parquetColumns = []parquet.Column{ //nolint:gochecknoglobals
+	{Name: "name", Type: parquet.ByteArray, Kind: parquet.KindString},
+	{Name: "id", Type: parquet.ByteArray, Kind: parquet.KindString},
+	{Name: "score", Type: parquet.Int64, Optional: true},
+	{Name: "at", Type: parquet.Int64, Kind: parquet.KindTimestamp, Unit: time.Millisecond},
+}
+
+func TestParquet(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "test.parquet")
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	writeParquet(t, file, parquetColumns, [][]any{
+		{"alice", "a", int64(1), at},
+		{"bob", "b", nil, at},
+		{"carol", "c", int64(3), at},
+		{"dave", "d", int64(4), at},
+		{"eve", "e", int64(-5), at},
+	})
+
+	opts := yuccaTable.BuildOptions{KeyField: "id", IndexInterval: 2}
+
+	for _, cache := range []*yuccaTable.BlockCache{nil, yuccaTable.NewBlockCache(1 << 20)} {
+		opts.BlockCache = cache
+
+		logger := &recordLogger{}
+
+		table, err := yuccaTable.LoadTable(file, logger, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer table.Close()
+
+		if table.File() != file {
+			t.Fatalf("expected file %q, but got %q", file, table.File())
+		}
+
+		if want := []string{"id", "name", "score", "at"}; !reflect.DeepEqual(table.Columns(), want) {
+			t.Fatalf("expected columns %v, but got %v", want, table.Columns())
+		}
+
+		wantSchema := yuccaTable.Schema{
+			{Name: "id", Type: yuccaTable.TypeString},
+			{Name: "name", Type: yuccaTable.TypeString},
+			{Name: "score", Type: yuccaTable.TypeInt64},
+			{Name: "at", Type: yuccaTable.TypeTimestamp},
+		}
+		if !reflect.DeepEqual(table.Schema(), wantSchema) {
+			t.Fatalf("expected schema %v, but got %v", wantSchema, table.Schema())
+		}
+
+		testGet(t, table, "a", []string{"alice", "1", "2024-01-02T03:04:05Z"})
+		testGet(t, table, "b", []string{"bob", "", "2024-01-02T03:04:05Z"})
+		testGet(t, table, "e", []string{"eve", "-5", "2024-01-02T03:04:05Z"})
+		testGet(t, table, "cc", nil)
+		testGet(t, table, "f", nil)
+
+		res, err := table.GetColumns("d", []string{"score"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(res.Values, []string{"4"}) {
+			t.Fatalf("unexpected values: %v", res.Values)
+		}
+
+		scan, err := table.Scan("b", "d", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(scan.Keys, []string{"b", "c"}) {
+			t.Fatalf("unexpected scan keys: %v", scan.Keys)
+		}
+
+		// the first load converts, and the second reuses the converted file
+		if converted := logger.contains("converted to"); converted != (cache == nil) {
+			t.Fatalf("expected converted %v, but got %v: %v", cache == nil, converted, logger.messages)
+		}
+	}
+}
+
+func TestParquetErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.parquet")
+
+	writeParquet(t, file, parquetColumns, [][]any{
+		{"bob", "b", int64(2), time.Unix(0, 0)},
+		{"alice", "a", int64(1), time.Unix(0, 0)},
+	})
+
+	if _, err := yuccaTable.BuildTable(file, &recordLogger{}, yuccaTable.BuildOptions{KeyField: "id"}); !errors.Is(err, yuccaTable.ErrKeysNotSorted) {
+		t.Fatalf("expected ErrKeysNotSorted, but got %v", err)
+	}
+
+	if _, err := yuccaTable.BuildTable(file, &recordLogger{}, yuccaTable.BuildOptions{}); !errors.Is(err, yuccaTable.ErrNoKeyColumn) {
+		t.Fatalf("expected ErrNoKeyColumn, but got %v", err)
+	}
+
+	if _, err := yuccaTable.BuildTable(file, &recordLogger{}, yuccaTable.BuildOptions{KeyField: "id", Header: true}); !errors.Is(err, yuccaTable.ErrUnsupportedOption) {
+		t.Fatalf("expected ErrUnsupportedOption, but got %v", err)
+	}
+
+	notParquet := filepath.Join(dir, "csv.parquet")
+	writeCsv(t, notParquet, "a,1")
+
+	if _, err := yuccaTable.BuildTable(notParquet, &recordLogger{}, yuccaTable.BuildOptions{}); !errors.Is(err, parquet.ErrCorrupted) {
+		t.Fatalf("expected ErrCorrupted, but got %v", err)
+	}
+}
+
+func TestParquetSort(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.parquet")
+	at := time.Unix(0, 0).UTC()
+
+	writeParquet(t, file, parquetColumns, [][]any{
+		{"carol", "c", int64(3), at},
+		{"alice", "a", int64(1), at},
+		{"dave", "d", int64(4), at},
+		{"bob", "b", nil, at},
+		{"alice2", "a", int64(5), at},
+	})
+
+	// runs of about one row
+	opts := yuccaTable.BuildOptions{KeyField: "id", IndexInterval: 2, Sort: true, SortMemory: 1, SortDir: dir}
+
+	table, err := yuccaTable.LoadTable(file, &recordLogger{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	scan, err := table.Scan("", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(scan.Keys, want) {
+		t.Fatalf("expected keys %v, but got %v", want, scan.Keys)
+	}
+
+	// equal keys keep their order, so the first is kept
+	testGet(t, table, "a", []string{"alice", "1", "1970-01-01T00:00:00Z"})
+
+	testGet(t, table, "b", []string{"bob", "", "1970-01-01T00:00:00Z"})
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected only the parquet and the converted file, but got %v", entries)
+	}
+
+	opts.Duplicates = yuccaTable.DuplicateReject
+
+	if _, err := yuccaTable.BuildTable(file, &recordLogger{}, opts); !errors.Is(err, yuccaTable.ErrDuplicateKey) {
+		t.Fatalf("expected ErrDuplicateKey, but got %v", err)
+	}
+}
+
+func TestParquetModified(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "test.parquet")
+	at := time.Unix(0, 0).UTC()
+
+	writeParquet(t, file, parquetColumns, [][]any{
+		{"alice", "a", int64(1), at},
+		{"bob", "b", int64(2), at},
+	})
+
+	opts := yuccaTable.BuildOptions{KeyField: "id", Verify: yuccaTable.VerifyChecksum}
+
+	table, err := yuccaTable.LoadTable(file, &recordLogger{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.Close()
+
+	stat, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// same size and modification time, but different values
+	writeParquet(t, file, parquetColumns, [][]any{
+		{"alice", "a", int64(3), at},
+		{"bob", "b", int64(4), at},
+	})
+
+	if err := os.Chtimes(file, stat.ModTime(), stat.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := &recordLogger{}
+
+	table, err = yuccaTable.LoadTable(file, logger, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	if !logger.contains("checksum mismatch") || !logger.contains("converted to") {
+		t.Fatalf("expected conversion for checksum mismatch, but got %v", logger.messages)
+	}
+
+	testGet(t, table, "a", []string{"alice", "3", "1970-01-01T00:00:00Z"})
+}
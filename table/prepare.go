@@ -0,0 +1,286 @@
+package table
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yokomotod/yuccadb/bgzf"
+	"github.com/yokomotod/yuccadb/internals/humanize"
+)
+
+const preparedSuffix = ".prepared"
+
+// PreparedFile returns the path of the file which the Sort and BadRows options write
+// the sorted or good rows of dataFile to, when dataFile cannot be loaded as it is.
+// Compressed files with blocks much larger than bgzf.BlockSize are also rewritten to it in smaller blocks.
+func PreparedFile(dataFile string) string {
+	return dataFile + preparedSuffix
+}
+
+// fileStat identifies a version of a file.
+type fileStat struct {
+	size     int64
+	modTime  time.Time
+	checksum uint32
+}
+
+// mayPrepare reports whether the table may load the prepared file instead of the data file.
+func (t *Table) mayPrepare() bool {
+	return t.opts.Sort || t.opts.BadRows != BadRowsFail || t.compressed
+}
+
+// prepareFile writes the rows of the data file to preparedFile, and leaves the data file as is.
+// Bad rows are skipped unless BadRowsFail, and appended to the quarantine file for BadRowsQuarantine.
+// Rows are sorted by key with an external merge sort if sortRows is true.
+// Compressed files are written in blocks of bgzf.BlockSize.
+// Rows are copied byte for byte, and comment and empty lines are kept with the row which follows them.
+// The data file is recorded in t.source, so that the index of the prepared file can be checked against it.
+// It returns the number of bad rows.
+func (t *Table) prepareFile(file, preparedFile string, sortRows bool) (int64, error) {
+	time0 := time.Now()
+
+	src, err := os.Open(file)
+	if err != nil {
+		return 0, fmt.Errorf("os.Open(%q): %w", file, err)
+	}
+	defer src.Close()
+
+	stat, err := src.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("file.Stat: %w", err)
+	}
+
+	hash := crc32.NewIEEE()
+	tee := io.TeeReader(src, hash)
+
+	var data io.Reader = tee
+	if t.compressed {
+		data = bgzf.NewReader(tee)
+	}
+
+	var quarantine io.Writer = io.Discard
+
+	if t.opts.BadRows == BadRowsQuarantine {
+		quarantineFile := t.quarantineFile(file)
+
+		f, err := os.OpenFile(quarantineFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644) //nolint:gosec,mnd
+		if err != nil {
+			return 0, fmt.Errorf("os.OpenFile(%q): %w", quarantineFile, err)
+		}
+		defer f.Close()
+
+		quarantine = f
+	}
+
+	var rows *sorter
+
+	if sortRows {
+		dir, err := os.MkdirTemp(t.opts.SortDir, "yuccadb-sort-")
+		if err != nil {
+			return 0, fmt.Errorf("os.MkdirTemp: %w", err)
+		}
+		defer os.RemoveAll(dir)
+
+		rows = t.newSorter(file, dir)
+	}
+
+	// write to a temporary file and rename so that readers never see a partial file
+	tmpFile := preparedFile + ".tmp"
+
+	dst, err := os.Create(tmpFile)
+	if err != nil {
+		return 0, fmt.Errorf("os.Create(%q): %w", tmpFile, err)
+	}
+	defer os.Remove(tmpFile)
+
+	var compressor *bgzf.Writer
+
+	var out io.Writer = dst
+	if t.compressed {
+		compressor = bgzf.NewWriter(dst)
+		out = compressor
+	}
+
+	writer := bufio.NewWriter(out)
+
+	bad, err := t.copyRows(data, writer, quarantine, rows)
+	if err != nil {
+		dst.Close()
+
+		return 0, err
+	}
+
+	// hash the rest of the file, e.g. the end-of-file block of compressed files
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		dst.Close()
+
+		return 0, fmt.Errorf("io.Copy: %w", err)
+	}
+
+	if err := writer.Flush(); err != nil {
+		dst.Close()
+
+		return 0, fmt.Errorf("Flush: %w", err)
+	}
+
+	if compressor != nil {
+		if err := compressor.Close(); err != nil {
+			dst.Close()
+
+			return 0, fmt.Errorf("bgzf.Writer.Close: %w", err)
+		}
+	}
+
+	if err := dst.Close(); err != nil {
+		return 0, fmt.Errorf("file.Close: %w", err)
+	}
+
+	if err := os.Rename(tmpFile, preparedFile); err != nil {
+		return 0, fmt.Errorf("os.Rename(%q, %q): %w", tmpFile, preparedFile, err)
+	}
+
+	t.source = fileStat{stat.Size(), stat.ModTime(), hash.Sum32()}
+
+	t.Logger.Infof("Prepared %q from %q with %d bad rows (%v)", preparedFile, file, bad, time.Since(time0))
+
+	return bad, nil
+}
+
+// copyRows copies the rows of data to dst, sorted by rows if not nil, and the bad rows to quarantine.
+// It returns the number of bad rows.
+func (t *Table) copyRows(data io.Reader, dst *bufio.Writer, quarantine io.Writer, rows *sorter) (int64, error) {
+	recorder := &recordingReader{r: data}
+
+	var (
+		bad, count int64
+		first      = true
+	)
+
+	// the bytes before the first row are the header, if any
+	header := func(offset int64) error {
+		if !first {
+			return nil
+		}
+
+		first = false
+
+		_, err := dst.WriteString(recorder.take(offset))
+
+		return err //nolint:wrapcheck
+	}
+
+	if _, err := t.validateRows(recorder, func(p Problem) error {
+		if !p.Kind.bad() || t.opts.BadRows == BadRowsFail {
+			// copied with the next row
+			return nil
+		}
+
+		if err := header(p.Offset); err != nil {
+			return err
+		}
+
+		if bad == 0 {
+			t.Logger.Infof("Skipping bad rows, the first at line %d: %s\n", p.Line, p.Message)
+		}
+
+		bad++
+
+		_, err := io.WriteString(quarantine, recorder.take(p.end))
+
+		return err //nolint:wrapcheck
+	}, func(key string, offset, end int64) error {
+		if err := header(offset); err != nil {
+			return err
+		}
+
+		count++
+
+		if rows != nil {
+			return rows.add(key, []string{recorder.take(end)})
+		}
+
+		_, err := dst.WriteString(recorder.take(end))
+
+		return err //nolint:wrapcheck
+	}); err != nil {
+		return 0, err
+	}
+
+	if err := header(recorder.end()); err != nil {
+		return 0, fmt.Errorf("Write: %w", err)
+	}
+
+	if rows != nil {
+		if err := rows.merge(func(row []string) error {
+			// the key is followed by the raw row
+			_, err := dst.WriteString(row[1])
+
+			return err //nolint:wrapcheck
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	// the bytes after the last row, e.g. comment lines at the end of the file
+	if _, err := dst.WriteString(recorder.take(recorder.end())); err != nil {
+		return 0, fmt.Errorf("Write: %w", err)
+	}
+
+	t.Logger.Debugf("Copied %s rows, skipped %s bad rows\n", humanize.Comma(count), humanize.Comma(bad))
+
+	return bad, nil
+}
+
+// removePrepared removes the prepared file of the data file and its index, if any,
+// once the data file itself can be loaded.
+func (t *Table) removePrepared(file string) {
+	preparedFile := PreparedFile(file)
+
+	for _, f := range []string{preparedFile, IndexFile(preparedFile)} {
+		if err := os.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+			t.Logger.Infof("Failed to remove stale %q: %v\n", f, err)
+		}
+	}
+}
+
+// recordingReader keeps the bytes read from r from offset base,
+// so that rows can be cut out at the offsets reported by a row reader which reads ahead.
+type recordingReader struct {
+	r    io.Reader
+	buf  []byte
+	base int64
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+
+	return n, err //nolint:wrapcheck
+}
+
+// end returns the offset of the end of the bytes read so far.
+func (r *recordingReader) end() int64 {
+	return r.base + int64(len(r.buf))
+}
+
+// take returns the bytes up to offset, ending with a newline unless empty, and forgets them.
+func (r *recordingReader) take(offset int64) string {
+	n := offset - r.base
+	s := string(r.buf[:n])
+
+	r.buf = r.buf[:copy(r.buf, r.buf[n:])]
+	r.base = offset
+
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		// the last row of a file without a trailing newline
+		s += "\n"
+	}
+
+	return s
+}
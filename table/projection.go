@@ -0,0 +1,102 @@
+package table
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNoColumnNames = errors.New("table has no column names")
+
+// projection selects value columns, so that reads copy only the needed fields.
+type projection struct {
+	positions []int
+	fields    []string    // fields of JSONL objects, instead of positions
+	columns   columnIndex // positions in projected values
+}
+
+// newProjection resolves column names to positions in values.
+func (t *Table) newProjection(columns []string) (*projection, error) {
+	if t.opts.Format == FormatJSONL {
+		p := &projection{fields: columns, columns: make(columnIndex, len(columns))}
+		for i, column := range columns {
+			p.columns[column] = i
+		}
+
+		return p, nil
+	}
+
+	if t.columnIndex == nil {
+		return nil, ErrNoColumnNames
+	}
+
+	p := &projection{
+		positions: make([]int, len(columns)),
+		columns:   make(columnIndex, len(columns)),
+	}
+
+	for i, column := range columns {
+		pos, ok := t.columnIndex[column]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownColumn, column)
+		}
+
+		p.positions[i] = pos
+		p.columns[column] = i
+	}
+
+	return p, nil
+}
+
+// project copies values, keeping only the projected columns in the requested order.
+// A nil projection keeps all columns.
+func (p *projection) project(values []string) ([]string, error) {
+	if p == nil || values == nil {
+		return copyValues(values), nil
+	}
+
+	if p.fields != nil {
+		// values of JSONL tables are the raw object
+		return jsonFields(values[0], p.fields)
+	}
+
+	projected := make([]string, len(p.positions))
+
+	for i, pos := range p.positions {
+		if pos < len(values) {
+			projected[i] = values[pos]
+		}
+	}
+
+	return projected, nil
+}
+
+// GetColumns is like Get, but returns only the columns in the order.
+// The Result is addressable by the column names.
+func (t *Table) GetColumns(key string, columns []string) (Result, error) {
+	p, err := t.newProjection(columns)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return t.get(key, p)
+}
+
+// BulkGetColumns is like BulkGet, but returns only the columns in the order.
+func (t *Table) BulkGetColumns(keys []string, columns []string) (BulkResult, error) {
+	p, err := t.newProjection(columns)
+	if err != nil {
+		return BulkResult{}, err
+	}
+
+	return t.bulkGet(keys, p)
+}
+
+// ScanColumns is like Scan, but returns only the columns in the order.
+func (t *Table) ScanColumns(start, end string, limit int, columns []string) (ScanResult, error) {
+	p, err := t.newProjection(columns)
+	if err != nil {
+		return ScanResult{}, err
+	}
+
+	return t.scan(start, end, limit, p)
+}
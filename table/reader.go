@@ -0,0 +1,256 @@
+package table
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/yokomotod/yuccadb/sstable"
+)
+
+// rowReader reads rows as columns, implemented by csv.Reader and jsonlReader.
+type rowReader interface {
+	Read() ([]string, error)
+	InputOffset() int64
+	FieldPos(field int) (line, column int)
+}
+
+// newRowReader returns a reader of the table format.
+// If reuseRecord is true, callers must copy rows to keep them.
+func (t *Table) newRowReader(r io.Reader, reuseRecord bool) rowReader { //nolint:ireturn
+	switch t.opts.Format { //nolint:exhaustive
+	case FormatJSONL:
+		return newJSONLReader(r, t.keyField(), reuseRecord)
+	case FormatSSTable, FormatParquet:
+		// Parquet tables read the converted sstable file
+		return sstable.NewRowReader(r, reuseRecord)
+	}
+
+	reader := t.newCSVReader(r)
+	reader.ReuseRecord = reuseRecord
+	reader.FieldsPerRecord = t.fields
+
+	return reader
+}
+
+// fixFields makes a CSV reader reject rows with a different number of fields than the Columns option.
+// Otherwise the first row read, which may be the header, sets the number of fields,
+// and rows of the Schema option are checked by validateRow with a more specific error.
+func (t *Table) fixFields(reader rowReader) {
+	if r, ok := reader.(*csv.Reader); ok && t.opts.Columns != nil {
+		r.FieldsPerRecord = len(t.opts.Columns)
+	}
+}
+
+// fieldsPerRecord returns the number of fields of every row of a CSV reader, 0 for other readers.
+func fieldsPerRecord(reader rowReader) int {
+	if r, ok := reader.(*csv.Reader); ok {
+		return r.FieldsPerRecord
+	}
+
+	return 0
+}
+
+// keyField returns the KeyField option or its default.
+func (t *Table) keyField() string {
+	if t.opts.KeyField == "" {
+		return defaultKeyField
+	}
+
+	return t.opts.KeyField
+}
+
+var ErrUnsupportedOption = errors.New("option is not supported for the format")
+
+// validateFormat checks that options are supported by the format.
+func (t *Table) validateFormat() error {
+	if err := t.validateBadRows(); err != nil {
+		return err
+	}
+
+	if (t.opts.Format == FormatSSTable || t.opts.Format == FormatParquet) && t.compressed {
+		return fmt.Errorf("%w: compressed files are not supported for %s", ErrUnsupportedOption, t.opts.Format)
+	}
+
+	if t.opts.Format == FormatSSTable && t.opts.Sort {
+		return fmt.Errorf("%w: sort is not supported for %s", ErrUnsupportedOption, t.opts.Format)
+	}
+
+	if t.opts.Format != FormatJSONL && t.opts.Format != FormatSSTable && t.opts.Format != FormatParquet {
+		return nil
+	}
+
+	if t.opts.Header || t.opts.Columns != nil || t.opts.Schema != nil ||
+		t.opts.KeyColumn != 0 || t.opts.KeyColumns != nil {
+		return fmt.Errorf("%w: header, columns, schema and key columns are not supported for %s",
+			ErrUnsupportedOption, t.opts.Format)
+	}
+
+	return nil
+}
+
+// newCSVReader returns a csv.Reader configured by the build options.
+// ReuseRecord is enabled, so callers must copy rows to keep them.
+func (t *Table) newCSVReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	if t.opts.Delimiter != 0 {
+		reader.Comma = t.opts.Delimiter
+	}
+
+	reader.Comment = t.opts.Comment
+	reader.LazyQuotes = t.opts.LazyQuotes
+	reader.TrimLeadingSpace = t.opts.TrimLeadingSpace
+
+	return reader
+}
+
+var ErrNoKeyColumn = errors.New("row has no key column")
+
+// splitRow splits cols into the key and the other values.
+// If the key is not only the first column, values are appended to buf[:0] to avoid allocations.
+func (t *Table) splitRow(cols, buf []string) (string, []string, error) {
+	if t.maxKeyColumn >= len(cols) {
+		return "", nil, fmt.Errorf("%w: key column %d, but %d columns", ErrNoKeyColumn, t.maxKeyColumn, len(cols))
+	}
+
+	if len(t.keyColumns) == 1 {
+		keyColumn := t.keyColumns[0]
+		if keyColumn == 0 {
+			return cols[0], cols[1:], nil
+		}
+
+		buf = append(buf[:0], cols[:keyColumn]...)
+		buf = append(buf, cols[keyColumn+1:]...)
+
+		return cols[keyColumn], buf, nil
+	}
+
+	parts := make([]string, len(t.keyColumns))
+	for i, keyColumn := range t.keyColumns {
+		parts[i] = cols[keyColumn]
+	}
+
+	buf = buf[:0]
+
+	for i, col := range cols {
+		if !slices.Contains(t.keyColumns, i) {
+			buf = append(buf, col)
+		}
+	}
+
+	return strings.Join(parts, t.keySeparator), buf, nil
+}
+
+// rowScanner reads rows of a block in ascending key order.
+// It keeps the row which stopped the last scan, so that following scans for greater keys can start from it.
+type rowScanner struct {
+	table  *Table
+	reader rowReader
+	key    string
+	values []string
+	peeked bool
+}
+
+func (t *Table) newRowScanner(r io.Reader) *rowScanner {
+	return &rowScanner{
+		table:  t,
+		reader: t.newRowReader(r, true),
+	}
+}
+
+// read returns the next row.
+// The returned values are only valid until the next read due to ReuseRecord.
+func (s *rowScanner) read() (string, []string, error) {
+	if s.peeked {
+		s.peeked = false
+
+		return s.key, s.values, nil
+	}
+
+	cols, err := s.reader.Read()
+	if err != nil {
+		return "", nil, fmt.Errorf("reader.Read: %w", err)
+	}
+
+	s.key, s.values, err = s.table.splitRow(cols, s.values)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return s.key, s.values, nil
+}
+
+// readLast returns the values of the last of rows with key, given the values of the first one.
+// The returned slice is a copy, since the following rows are read to find the last one.
+func (s *rowScanner) readLast(key string, values []string) ([]string, error) {
+	last := slices.Clone(values)
+
+	for {
+		rowKey, values, err := s.read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return last, nil
+			}
+
+			return nil, err
+		}
+
+		if rowKey != key {
+			s.unread()
+
+			return last, nil
+		}
+
+		last = append(last[:0], values...)
+	}
+}
+
+// unread makes the next read return the last row again.
+func (s *rowScanner) unread() {
+	s.peeked = true
+}
+
+// scanFile returns the values for key, or nil when a greater key is reached.
+// Of rows with equal keys, it returns the first one, or the last one for DuplicateKeepLast.
+// The returned slice is only valid until the next read due to ReuseRecord.
+func (t *Table) scanFile(scanner *rowScanner, key string) ([]string, error) {
+	var scannedLines int64
+
+	for {
+		rowKey, values, err := scanner.read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, nil
+			}
+
+			return nil, err
+		}
+
+		if rowKey == key {
+			if t.opts.Duplicates == DuplicateKeepLast {
+				return scanner.readLast(key, values)
+			}
+
+			return values, nil
+		}
+
+		if rowKey > key {
+			// keys are sorted, means not found
+			scanner.unread()
+
+			return nil, nil
+		}
+
+		scannedLines++
+
+		if scannedLines > t.maxBlockRows {
+			// should never happen
+			return nil, fmt.Errorf("too many scanned lines: %d", scannedLines)
+		}
+	}
+}
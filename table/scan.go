@@ -0,0 +1,148 @@
+package table
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+type ScanResult struct {
+	Keys   []string
+	Values [][]string
+}
+
+// add copies values due to ReuseRecord, keeping only the projected columns if p is not nil.
+func (r *ScanResult) add(key string, values []string, p *projection) error {
+	projected, err := p.project(values)
+	if err != nil {
+		return fmt.Errorf("project: %w", err)
+	}
+
+	r.Keys = append(r.Keys, key)
+	r.Values = append(r.Values, projected)
+
+	return nil
+}
+
+func (r *ScanResult) len() int {
+	return len(r.Keys)
+}
+
+// inRange reports whether key is in the half-open range [start, end).
+// Empty end means no upper bound.
+func inRange(key, start, end string) bool {
+	return key >= start && (end == "" || key < end)
+}
+
+// Scan returns rows whose keys are in the half-open range [start, end) in ascending order.
+// Empty end means no upper bound, and limit <= 0 means no limit.
+func (t *Table) Scan(start, end string, limit int) (ScanResult, error) {
+	return t.scan(start, end, limit, nil)
+}
+
+func (t *Table) scan(start, end string, limit int, p *projection) (ScanResult, error) {
+	if end != "" && end <= start {
+		return ScanResult{}, nil
+	}
+
+	it, err := t.Iter(start, end)
+	if err != nil {
+		return ScanResult{}, fmt.Errorf("Iter: %w", err)
+	}
+	defer it.Close()
+
+	res := ScanResult{}
+
+	for (limit <= 0 || res.len() < limit) && it.Next() {
+		if err := res.add(it.Key(), it.Values(), p); err != nil {
+			return ScanResult{}, err
+		}
+	}
+
+	if err := it.Err(); err != nil {
+		return ScanResult{}, err
+	}
+
+	return res, nil
+}
+
+// ReverseScan is like Scan, but returns rows in descending order.
+// The limit is applied from the end of the range.
+func (t *Table) ReverseScan(start, end string, limit int) (ScanResult, error) {
+	if end != "" && end <= start {
+		return ScanResult{}, nil
+	}
+
+	// blocks after the first index key >= end have no keys in range
+	idx := len(t.index)
+	if end != "" {
+		idx = sort.Search(len(t.index), func(i int) bool {
+			return t.index[i].key >= end
+		})
+	}
+
+	res := ScanResult{}
+
+	// the file can only be read forward, so read each block forward and emit it backward
+	for i := idx - 1; i >= 0 && (limit <= 0 || res.len() < limit); i-- {
+		limitOffset := t.size
+		if i+1 < len(t.index) {
+			limitOffset = t.index[i+1].offset
+		}
+
+		block, err := t.readBlock(t.index[i].offset, limitOffset, start, end)
+		if err != nil {
+			return ScanResult{}, fmt.Errorf("readBlock: %w", err)
+		}
+
+		for j := block.len() - 1; j >= 0 && (limit <= 0 || res.len() < limit); j-- {
+			res.Keys = append(res.Keys, block.Keys[j])
+			res.Values = append(res.Values, block.Values[j])
+		}
+
+		if t.index[i].key < start {
+			// preceding blocks have only keys before start
+			break
+		}
+	}
+
+	return res, nil
+}
+
+// readBlock reads rows which start in [offset, limitOffset) and have keys in [start, end).
+// Rows with equal keys are in the same block, and are kept by the duplicate policy.
+func (t *Table) readBlock(offset, limitOffset int64, start, end string) (ScanResult, error) {
+	scanner := t.newRowScanner(t.section(offset, limitOffset))
+
+	block := ScanResult{}
+
+	for {
+		key, values, err := scanner.read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return ScanResult{}, err
+		}
+
+		if inRange(key, start, end) {
+			if n := block.len(); n > 0 && block.Keys[n-1] == key {
+				switch t.opts.Duplicates { //nolint:exhaustive
+				case DuplicateKeepFirst:
+					continue
+				case DuplicateKeepLast:
+					block.Keys = block.Keys[:n-1]
+					block.Values = block.Values[:n-1]
+				}
+			}
+
+			if err := block.add(key, values, nil); err != nil {
+				return ScanResult{}, err
+			}
+		}
+	}
+
+	return block, nil
+}
@@ -0,0 +1,148 @@
+package table
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ColumnType is the type of values in a column.
+// Empty values are nulls and valid for any type.
+type ColumnType int
+
+const (
+	TypeString ColumnType = iota
+	TypeInt64
+	TypeFloat64
+	// TypeBool accepts values accepted by strconv.ParseBool.
+	TypeBool
+	// TypeTimestamp accepts RFC 3339 and BigQuery export formats, see timestampLayouts.
+	TypeTimestamp
+	// TypeJSON accepts valid JSON.
+	TypeJSON
+)
+
+func (c ColumnType) String() string {
+	switch c {
+	case TypeString:
+		return "string"
+	case TypeInt64:
+		return "int64"
+	case TypeFloat64:
+		return "float64"
+	case TypeBool:
+		return "bool"
+	case TypeTimestamp:
+		return "timestamp"
+	case TypeJSON:
+		return "json"
+	default:
+		return "unknown"
+	}
+}
+
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Schema names and types all columns including the key column in file order.
+type Schema []Column
+
+func (s Schema) names() []string {
+	names := make([]string, len(s))
+	for i, c := range s {
+		names[i] = c.Name
+	}
+
+	return names
+}
+
+var ErrInvalidValue = errors.New("invalid value")
+
+// validateRow checks that cols match the schema. line is used for error messages.
+func (s Schema) validateRow(cols []string, line int) error {
+	problem := s.check(cols)
+	if problem == "" {
+		return nil
+	}
+
+	// e.g. "line 2: 3 columns, but ..." and "line 2, column ..."
+	sep := ", "
+	if len(cols) != len(s) {
+		sep = ": "
+	}
+
+	return fmt.Errorf("%w: line %d%s%s", ErrInvalidValue, line, sep, problem)
+}
+
+// check returns the first mismatch of cols and the schema, or "" if they match.
+func (s Schema) check(cols []string) string {
+	if len(cols) != len(s) {
+		return fmt.Sprintf("%d columns, but schema has %d", len(cols), len(s))
+	}
+
+	for i, c := range s {
+		if err := c.Type.validate(cols[i]); err != nil {
+			return fmt.Sprintf("column %q: %q is not %s", c.Name, cols[i], c.Type)
+		}
+	}
+
+	return ""
+}
+
+var errInvalidJSON = errors.New("invalid JSON")
+
+func (c ColumnType) validate(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	var err error
+
+	switch c {
+	case TypeString:
+	case TypeInt64:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case TypeFloat64:
+		_, err = strconv.ParseFloat(value, 64)
+	case TypeBool:
+		_, err = strconv.ParseBool(value)
+	case TypeTimestamp:
+		_, err = parseTimestamp(value)
+	case TypeJSON:
+		if !json.Valid([]byte(value)) {
+			err = errInvalidJSON
+		}
+	}
+
+	return err
+}
+
+// timestampLayouts are tried in order by parseTimestamp.
+// BigQuery exports TIMESTAMP as "2006-01-02 15:04:05.999999 UTC", and DATETIME without the zone.
+var timestampLayouts = []string{ //nolint:gochecknoglobals
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999 MST",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	time.DateOnly,
+}
+
+func parseTimestamp(value string) (time.Time, error) {
+	var err error
+
+	for _, layout := range timestampLayouts {
+		var t time.Time
+
+		t, err = time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("time.Parse: %w", err)
+}
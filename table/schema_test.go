@@ -0,0 +1,160 @@
+package table_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+var testSchema = yuccaTable.Schema{ //nolint:gochecknoglobals
+	{Name: "id", Type: yuccaTable.TypeString},
+	{Name: "count", Type: yuccaTable.TypeInt64},
+	{Name: "ratio", Type: yuccaTable.TypeFloat64},
+	{Name: "active", Type: yuccaTable.TypeBool},
+	{Name: "created", Type: yuccaTable.TypeTimestamp},
+	{Name: "attrs", Type: yuccaTable.TypeJSON},
+}
+
+func TestSchemaValidation(t *testing.T) {
+	t.Parallel()
+
+	const first = "a,1,1.5,false,2024-01-01T00:00:00Z,[]"
+
+	cases := []struct {
+		name    string
+		rows    []string
+		wantErr string
+	}{
+		{"valid", []string{first, `b,2,0.5,true,2024-01-02 03:04:05.123456 UTC,"{""k"":1}"`}, ""},
+		{"nulls", []string{first, `b,,,,,`}, ""},
+		{"int", []string{first, `b,x,0.5,true,2024-01-02,{}`}, `line 2, column "count": "x" is not int64`},
+		{"bool", []string{first, `b,2,0.5,yes,2024-01-02,{}`}, `line 2, column "active": "yes" is not bool`},
+		{"timestamp", []string{first, `b,2,0.5,true,yesterday,{}`}, `line 2, column "created": "yesterday" is not timestamp`},
+		{"json", []string{first, `b,2,0.5,true,2024-01-02,{`}, `line 2, column "attrs": "{" is not json`},
+		{"columns", []string{"0,1"}, `line 1: 2 columns, but schema has 6`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			testFile := filepath.Join(t.TempDir(), "test.csv")
+			writeCsv(t, testFile, c.rows...)
+
+			opts := yuccaTable.BuildOptions{Schema: testSchema}
+
+			table, err := yuccaTable.BuildTable(testFile, &recordLogger{}, opts)
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				table.Close()
+
+				return
+			}
+
+			if !errors.Is(err, yuccaTable.ErrInvalidValue) || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("expected error %q, but got %v", c.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestResultDecode(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, testFile,
+		`a,42,0.5,true,2024-01-02 03:04:05.123456 UTC,"{""tags"":[""x"",""y""]}"`,
+		`b,,,,,`,
+	)
+
+	table, err := yuccaTable.BuildTable(testFile, &recordLogger{}, yuccaTable.BuildOptions{Schema: testSchema})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	res, err := table.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := res.Int64("count"); err != nil || v != 42 {
+		t.Fatalf("Int64: %v, %v", v, err)
+	}
+
+	if v, err := res.Float64("ratio"); err != nil || v != 0.5 {
+		t.Fatalf("Float64: %v, %v", v, err)
+	}
+
+	if v, err := res.Bool("active"); err != nil || !v {
+		t.Fatalf("Bool: %v, %v", v, err)
+	}
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	if v, err := res.Time("created"); err != nil || !v.Equal(created) {
+		t.Fatalf("Time: %v, %v", v, err)
+	}
+
+	if _, err := res.Int64("missing"); !errors.Is(err, yuccaTable.ErrUnknownColumn) {
+		t.Fatalf("expected ErrUnknownColumn, but got %v", err)
+	}
+
+	type attrs struct {
+		Tags []string `json:"tags"`
+	}
+
+	type record struct {
+		Count    int64     `yucca:"count"`
+		Ratio    *float64  `yucca:"ratio"`
+		Active   bool      `yucca:"active"`
+		Created  time.Time `yucca:"created"`
+		Attrs    attrs     `yucca:"attrs"`
+		Ignored  string    `yucca:"-"`
+		Unmapped string
+	}
+
+	var got record
+	if err := res.ScanInto(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	ratio := 0.5
+	want := record{42, &ratio, true, created, attrs{[]string{"x", "y"}}, "", ""}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, but got %+v", want, got)
+	}
+
+	res, err = table.Get("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := res.Int64("count"); !errors.Is(err, yuccaTable.ErrNullValue) {
+		t.Fatalf("expected ErrNullValue, but got %v", err)
+	}
+
+	got = record{Count: 1, Ratio: &ratio}
+	if err := res.ScanInto(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, record{}) {
+		t.Fatalf("expected zero values for nulls, but got %+v", got)
+	}
+
+	var unknown struct {
+		ID string `yucca:"id"`
+	}
+
+	if err := res.ScanInto(&unknown); !errors.Is(err, yuccaTable.ErrUnknownColumn) {
+		t.Fatalf("expected ErrUnknownColumn for the key column, but got %v", err)
+	}
+}
@@ -0,0 +1,381 @@
+package table
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"unsafe"
+
+	"github.com/yokomotod/yuccadb/internals/humanize"
+	"github.com/yokomotod/yuccadb/sstable"
+)
+
+const (
+	defaultSortMemory = 64 << 20
+	// maxMergeRuns is the most runs merged at once, which bounds the open files far below common limits.
+	maxMergeRuns = 64
+)
+
+type sortRow struct {
+	key  string
+	cols []string
+}
+
+// sorter sorts rows with an external merge sort.
+// Rows are sorted in runs of about SortMemory bytes, which are written to dir
+// and merged at most maxMergeRuns at a time. Equal keys keep their order.
+type sorter struct {
+	table      *Table
+	name       string // name of the data in logs
+	dir        string
+	sortMemory int64
+	runs       []string
+	rows       []sortRow
+	bytes      int64
+	count      int64
+}
+
+func (t *Table) newSorter(name, dir string) *sorter {
+	sortMemory := t.opts.SortMemory
+	if sortMemory <= 0 {
+		sortMemory = defaultSortMemory
+	}
+
+	return &sorter{table: t, name: name, dir: dir, sortMemory: sortMemory}
+}
+
+// add adds a row, keeping cols until the row is written to a run.
+func (s *sorter) add(key string, cols []string) error {
+	const (
+		stringSize = int64(unsafe.Sizeof(""))
+		rowSize    = int64(unsafe.Sizeof(sortRow{}))
+	)
+
+	s.rows = append(s.rows, sortRow{key, cols})
+	s.count++
+
+	s.bytes += rowSize + int64(len(key)) + int64(len(cols))*stringSize
+	for _, col := range cols {
+		s.bytes += int64(len(col))
+	}
+
+	if s.bytes >= s.sortMemory {
+		return s.flush()
+	}
+
+	return nil
+}
+
+// flush writes the rows to a sorted run file.
+func (s *sorter) flush() error {
+	sort.SliceStable(s.rows, func(i, j int) bool {
+		return s.rows[i].key < s.rows[j].key
+	})
+
+	run := filepath.Join(s.dir, fmt.Sprintf("run-%06d", len(s.runs)))
+	if err := writeRun(run, s.rows); err != nil {
+		return err
+	}
+
+	s.runs = append(s.runs, run)
+
+	s.table.Logger.Infof("Sorting %q: wrote run %d with %s rows (%s rows so far)\n",
+		s.name, len(s.runs), humanize.Comma(int64(len(s.rows))), humanize.Comma(s.count))
+
+	clear(s.rows)
+	s.rows = s.rows[:0]
+	s.bytes = 0
+
+	return nil
+}
+
+// merge calls emit with all rows in key order, the key followed by the columns.
+func (s *sorter) merge(emit func(row []string) error) error {
+	m, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer m.close()
+
+	return m.each(emit)
+}
+
+// open returns a merger of all rows in key order, after merging the runs down to at most maxMergeRuns.
+func (s *sorter) open() (*merger, error) {
+	if len(s.rows) > 0 {
+		if err := s.flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.table.Logger.Infof("Sorting %q: merging %d runs\n", s.name, len(s.runs))
+
+	runs, err := reduceRuns(s.runs, s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return openMerger(runs)
+}
+
+// writeRun writes rows to a run file.
+func writeRun(run string, rows []sortRow) error {
+	w, err := createRun(run)
+	if err != nil {
+		return err
+	}
+	defer w.file.Close()
+
+	for _, r := range rows {
+		if err := w.write(r.key, r.cols); err != nil {
+			return err
+		}
+	}
+
+	return w.close()
+}
+
+// runWriter writes rows as the key followed by the columns in the sstable row encoding,
+// so that they can be read back with sstable.RowReader.
+type runWriter struct {
+	file   *os.File
+	writer *bufio.Writer
+	buf    []byte
+}
+
+func createRun(run string) (*runWriter, error) {
+	f, err := os.Create(run)
+	if err != nil {
+		return nil, fmt.Errorf("os.Create(%q): %w", run, err)
+	}
+
+	return &runWriter{file: f, writer: bufio.NewWriter(f)}, nil
+}
+
+func (w *runWriter) write(key string, cols []string) error {
+	w.buf = binary.AppendUvarint(w.buf[:0], uint64(len(cols)+1))
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(key)))
+	w.buf = append(w.buf, key...)
+
+	for _, col := range cols {
+		w.buf = binary.AppendUvarint(w.buf, uint64(len(col)))
+		w.buf = append(w.buf, col...)
+	}
+
+	if _, err := w.writer.Write(w.buf); err != nil {
+		return fmt.Errorf("Write: %w", err)
+	}
+
+	return nil
+}
+
+func (w *runWriter) close() error {
+	if err := w.writer.Flush(); err != nil {
+		return fmt.Errorf("Flush: %w", err)
+	}
+
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("file.Close: %w", err)
+	}
+
+	return nil
+}
+
+// reduceRuns merges more than maxMergeRuns runs in passes into at most maxMergeRuns larger runs in dir.
+func reduceRuns(runs []string, dir string) ([]string, error) {
+	for pass := 0; len(runs) > maxMergeRuns; pass++ {
+		merged := make([]string, 0, (len(runs)+maxMergeRuns-1)/maxMergeRuns)
+
+		// consecutive runs are merged in order, so that equal keys keep their order
+		for i := 0; i < len(runs); i += maxMergeRuns {
+			group := runs[i:min(i+maxMergeRuns, len(runs))]
+			run := filepath.Join(dir, fmt.Sprintf("merge-%d-%06d", pass, len(merged)))
+
+			if err := mergeToRun(group, run); err != nil {
+				return nil, err
+			}
+
+			merged = append(merged, run)
+		}
+
+		runs = merged
+	}
+
+	return runs, nil
+}
+
+// mergeToRun merges the runs into a new run file, and removes them.
+func mergeToRun(runs []string, run string) error {
+	w, err := createRun(run)
+	if err != nil {
+		return err
+	}
+	defer w.file.Close()
+
+	m, err := openMerger(runs)
+	if err != nil {
+		return err
+	}
+	defer m.close()
+
+	if err := m.each(func(row []string) error {
+		return w.write(row[0], row[1:])
+	}); err != nil {
+		return err
+	}
+
+	if err := w.close(); err != nil {
+		return err
+	}
+
+	for _, r := range runs {
+		if err := os.Remove(r); err != nil {
+			return fmt.Errorf("os.Remove(%q): %w", r, err)
+		}
+	}
+
+	return nil
+}
+
+// merger merges runs, which are opened at once, and reads their rows in key order.
+type merger struct {
+	files []*os.File
+	heap  runHeap
+	// last is the reader of the row returned last, which is advanced on the next call
+	last *runReader
+}
+
+func openMerger(runs []string) (*merger, error) {
+	m := &merger{
+		files: make([]*os.File, 0, len(runs)),
+		heap:  make(runHeap, 0, len(runs)),
+	}
+
+	for i, run := range runs {
+		f, err := os.Open(run)
+		if err != nil {
+			m.close()
+
+			return nil, fmt.Errorf("os.Open(%q): %w", run, err)
+		}
+
+		m.files = append(m.files, f)
+
+		r := &runReader{run: i, reader: sstable.NewRowReader(f, true)}
+		if ok, err := r.next(); err != nil {
+			m.close()
+
+			return nil, err
+		} else if ok {
+			m.heap = append(m.heap, r)
+		}
+	}
+
+	heap.Init(&m.heap)
+
+	return m, nil
+}
+
+// Read returns the next row, the key followed by the columns, or io.EOF after the last row.
+// The row is only valid until the next call.
+func (m *merger) Read() ([]string, error) {
+	if m.last != nil {
+		ok, err := m.last.next()
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			heap.Fix(&m.heap, 0)
+		} else {
+			heap.Pop(&m.heap)
+		}
+
+		m.last = nil
+	}
+
+	if len(m.heap) == 0 {
+		return nil, io.EOF
+	}
+
+	m.last = m.heap[0]
+
+	return m.last.row, nil
+}
+
+// each calls emit with each row. The row is only valid until emit returns.
+func (m *merger) each(emit func(row []string) error) error {
+	for {
+		row, err := m.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+
+		if err := emit(row); err != nil {
+			return err
+		}
+	}
+}
+
+func (m *merger) close() {
+	for _, f := range m.files {
+		f.Close()
+	}
+}
+
+type runReader struct {
+	run    int
+	reader *sstable.RowReader
+	row    []string
+}
+
+func (r *runReader) next() (bool, error) {
+	row, err := r.reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("reader.Read: %w", err)
+	}
+
+	r.row = row
+
+	return true, nil
+}
+
+// runHeap orders runs by their current keys, and equal keys by the runs,
+// so that the merge is stable.
+type runHeap []*runReader
+
+func (h runHeap) Len() int { return len(h) }
+
+func (h runHeap) Less(i, j int) bool {
+	if h[i].row[0] != h[j].row[0] {
+		return h[i].row[0] < h[j].row[0]
+	}
+
+	return h[i].run < h[j].run
+}
+
+func (h runHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *runHeap) Push(x any) { *h = append(*h, x.(*runReader)) } //nolint:forcetypeassert
+
+func (h *runHeap) Pop() any {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return r
+}
@@ -0,0 +1,209 @@
+package table_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/yokomotod/yuccadb/bgzf"
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+func TestSort(t *testing.T) {
+	t.Parallel()
+
+	const n = 5_000
+
+	lines := []string{"id,value"}
+	for _, i := range rand.New(rand.NewPCG(1, 2)).Perm(n) { //nolint:gosec
+		lines = append(lines, fmt.Sprintf("%05d,v%d", i, i))
+	}
+
+	// equal keys keep their order
+	lines = append(lines, "00010,second", "00010,third")
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, testFile, lines...)
+
+	opts := yuccaTable.BuildOptions{Header: true}
+
+	if _, err := yuccaTable.BuildTable(testFile, &recordLogger{}, opts); !errors.Is(err, yuccaTable.ErrKeysNotSorted) {
+		t.Fatalf("expected ErrKeysNotSorted without Sort, but got %v", err)
+	}
+
+	opts.Sort = true
+	// small enough for more runs than are merged at once
+	opts.SortMemory = 4 << 10
+	opts.SortDir = t.TempDir()
+
+	original, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := &recordLogger{}
+
+	table, err := yuccaTable.LoadTable(testFile, logger, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	if !logger.contains("wrote run 65 ") {
+		t.Fatalf("expected more runs than merged at once, but got logs %q", logger.messages)
+	}
+
+	if table.File() != testFile {
+		t.Fatalf("expected the table of %q, but got %q", testFile, table.File())
+	}
+
+	testGet(t, table, "00000", []string{"v0"})
+	testGet(t, table, "04999", []string{"v4999"})
+
+	if !slices.Equal(table.Columns(), []string{"id", "value"}) {
+		t.Fatalf("unexpected columns: %v", table.Columns())
+	}
+
+	if data, err := os.ReadFile(testFile); err != nil || string(data) != string(original) {
+		t.Fatalf("data file is modified: %v", err)
+	}
+
+	data, err := os.ReadFile(yuccaTable.PreparedFile(testFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sorted := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(sorted) != n+3 || sorted[0] != "id,value" {
+		t.Fatalf("unexpected sorted file with %d lines, header %q", len(sorted), sorted[0])
+	}
+
+	if !slices.Equal(sorted[11:14], []string{"00010,v10", "00010,second", "00010,third"}) {
+		t.Fatalf("equal keys are reordered: %q", sorted[11:14])
+	}
+
+	entries, err := os.ReadDir(opts.SortDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 0 {
+		t.Fatalf("temporary files are left: %v", entries)
+	}
+
+	// the prepared file is reused while the data file is unchanged
+	logger = &recordLogger{}
+
+	reloaded, err := yuccaTable.LoadTable(testFile, logger, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloaded.Close()
+
+	if !logger.contains("from index file") || logger.contains("preparing") {
+		t.Fatalf("expected to reuse the prepared file, but got logs %q", logger.messages)
+	}
+
+	testGet(t, reloaded, "00010", []string{"v10"})
+
+	// and sorted again once the data file changes
+	lines[1] = "99999,changed"
+	writeCsv(t, testFile, lines...)
+
+	logger = &recordLogger{}
+
+	changed, err := yuccaTable.LoadTable(testFile, logger, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer changed.Close()
+
+	if !logger.contains("modified since it was prepared") {
+		t.Fatalf("expected to sort the changed file, but got logs %q", logger.messages)
+	}
+
+	testGet(t, changed, "99999", []string{"changed"})
+}
+
+func TestSortKeepsRows(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.tsv")
+	writeCsv(t, testFile,
+		"# exported at 2024-01-01",
+		"b\t  2\t\"quoted\"",
+		"# a comment of a",
+		"a\t  1\tplain",
+	)
+
+	opts := yuccaTable.BuildOptions{
+		Delimiter: '\t', Comment: '#', TrimLeadingSpace: true, Sort: true, SortDir: t.TempDir(),
+	}
+
+	table, err := yuccaTable.BuildTable(testFile, &recordLogger{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	testGet(t, table, "a", []string{"1", "plain"})
+	testGet(t, table, "b", []string{"2", "quoted"})
+
+	data, err := os.ReadFile(yuccaTable.PreparedFile(testFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "# a comment of a\na\t  1\tplain\n# exported at 2024-01-01\nb\t  2\t\"quoted\"\n"
+	if string(data) != want {
+		t.Fatalf("expected sorted file %q, but got %q", want, data)
+	}
+}
+
+func TestSortCompressedJSONL(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.jsonl.gz")
+
+	f, err := os.Create(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := bgzf.NewWriter(f)
+	for _, key := range []string{"c", "a", "b"} {
+		fmt.Fprintf(w, "{\"key\": %q}\n", key)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Close()
+
+	opts := yuccaTable.BuildOptions{Sort: true, SortDir: t.TempDir()}
+
+	table, err := yuccaTable.BuildTable(testFile, &recordLogger{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	for _, key := range []string{"a", "b", "c"} {
+		testGet(t, table, key, []string{fmt.Sprintf("{\"key\": %q}", key)})
+	}
+
+	scan, err := table.Scan("", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(scan.Keys, []string{"a", "b", "c"}) {
+		t.Fatalf("unexpected scan keys: %v", scan.Keys)
+	}
+}
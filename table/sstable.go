@@ -0,0 +1,270 @@
+package table
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yokomotod/yuccadb/bgzf"
+	"github.com/yokomotod/yuccadb/internals/humanize"
+	"github.com/yokomotod/yuccadb/logger"
+	"github.com/yokomotod/yuccadb/sstable"
+)
+
+// loadSSTable reads the index from the sstable file and keeps the file open for reads.
+func (t *Table) loadSSTable(file string) error {
+	time0 := time.Now()
+
+	handle, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("os.Open(%q): %w", file, err)
+	}
+
+	if _, err := t.readSSTable(handle); err != nil {
+		handle.Close()
+
+		return err
+	}
+
+	t.timestamp = time.Now()
+
+	t.Logger.Infof("Loaded %q with %s items from sstable (%v)", file, humanize.Comma(t.count), t.timestamp.Sub(time0))
+
+	return nil
+}
+
+var errEmptySSTable = errors.New("sstable has no rows")
+
+// readSSTable reads the index of the sstable file, and returns its metadata.
+func (t *Table) readSSTable(handle *os.File) (*sstable.Metadata, error) {
+	stat, err := handle.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("file.Stat: %w", err)
+	}
+
+	meta, err := sstable.ReadMetadata(handle, stat.Size())
+	if err != nil {
+		return nil, fmt.Errorf("sstable.ReadMetadata: %w", err)
+	}
+
+	if len(meta.Blocks) == 0 {
+		return nil, errEmptySSTable
+	}
+
+	if t.opts.Verify != VerifyMetadata {
+		if err := meta.VerifyBlocks(handle); err != nil {
+			return nil, fmt.Errorf("VerifyBlocks: %w", err)
+		}
+	}
+
+	index := make([]indexEntry, 0, len(meta.Blocks)+1)
+	for _, block := range meta.Blocks {
+		index = append(index, indexEntry{block.FirstKey, block.Offset})
+	}
+
+	// add last key
+	if index[len(index)-1].key != meta.LastKey {
+		index = append(index, indexEntry{meta.LastKey, meta.LastOffset})
+	}
+
+	t.file = handle.Name()
+	t.handle = handle
+	t.reader = handle
+	t.index = index
+	t.maxBlockRows = meta.MaxBlockRows
+	t.count = meta.Count
+	t.size = meta.DataSize
+	t.fileSize = stat.Size()
+	t.modTime = stat.ModTime()
+
+	if err := t.initColumns(meta.Columns); err != nil {
+		return nil, err
+	}
+
+	// rows were converted from a table with the same number of fields as the columns
+	t.fields = len(t.columns)
+
+	// reading the keys of sstable rows is still much cheaper than parsing CSV
+	if err := t.buildBloom(); err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+// ConvertToSSTable converts the sorted data file to an sstable file, which FormatSSTable tables read.
+// opts describes the data file as for BuildTable, and the index options set the block size.
+// Column names of the data file, if any, are kept in the sstable file.
+func ConvertToSSTable(dataFile, sstFile string, logger logger.Logger, opts BuildOptions) error {
+	time0 := time.Now()
+
+	t, err := newTable(dataFile, logger, opts)
+	if err != nil {
+		return err
+	}
+
+	if t.opts.Format == FormatSSTable {
+		return fmt.Errorf("%w: %q is already an sstable", ErrUnsupportedOption, dataFile)
+	}
+
+	if t.opts.Format == FormatParquet {
+		return fmt.Errorf("%w: %q is converted by LoadTable", ErrUnsupportedOption, dataFile)
+	}
+
+	src, err := os.Open(dataFile)
+	if err != nil {
+		return fmt.Errorf("os.Open(%q): %w", dataFile, err)
+	}
+	defer src.Close()
+
+	var data io.Reader = src
+	if t.compressed {
+		data = bgzf.NewReader(src)
+	}
+
+	// write to a temporary file and rename so that readers never see a partial file
+	tmpFile := sstFile + ".tmp"
+
+	dst, err := os.Create(tmpFile)
+	if err != nil {
+		return fmt.Errorf("os.Create(%q): %w", tmpFile, err)
+	}
+	defer os.Remove(tmpFile)
+
+	count, err := t.convert(data, dst)
+	if err != nil {
+		dst.Close()
+
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("file.Close: %w", err)
+	}
+
+	if err := os.Rename(tmpFile, sstFile); err != nil {
+		return fmt.Errorf("os.Rename(%q, %q): %w", tmpFile, sstFile, err)
+	}
+
+	logger.Infof("Converted %q to %q with %s items (%v)", dataFile, sstFile, humanize.Comma(count), time.Since(time0))
+
+	return nil
+}
+
+func (t *Table) convert(src io.Reader, dst io.Writer) (int64, error) {
+	reader := t.newRowReader(src, true)
+
+	var header []string
+
+	if t.opts.Header {
+		cols, err := reader.Read()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, fmt.Errorf("reader.Read: %w", err)
+		}
+
+		header = slices.Clone(cols)
+	}
+
+	return t.convertRows(reader, header, dst, sstable.Source{})
+}
+
+// convertRows writes the rows of reader as an sstable, naming columns by header if not nil,
+// and recording source as the file which the rows were converted from.
+func (t *Table) convertRows(reader rowReader, header []string, dst io.Writer, source sstable.Source) (int64, error) {
+	if err := t.initColumns(header); err != nil {
+		return 0, err
+	}
+
+	writer := sstable.NewWriter(dst, sstable.WriterOptions{
+		BlockRows:  t.indexInterval,
+		BlockBytes: t.opts.IndexBytes,
+		Columns:    t.sstableColumns(),
+		Source:     source,
+	})
+
+	var count int64
+
+	var lastKey string
+
+	var buf []string
+
+	for {
+		cols, err := reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return 0, fmt.Errorf("reader.Read: %w", err)
+		}
+
+		if t.opts.Schema != nil {
+			line, _ := reader.FieldPos(0)
+			if err := t.opts.Schema.validateRow(cols, line); err != nil {
+				return 0, err
+			}
+		}
+
+		key, values, err := t.splitRow(cols, buf)
+		if err != nil {
+			return 0, err
+		}
+
+		buf = values
+
+		if key < lastKey {
+			return 0, fmt.Errorf("%w: %q, %q", ErrKeysNotSorted, lastKey, key)
+		}
+
+		if count > 0 && key == lastKey && t.opts.Duplicates == DuplicateReject {
+			line, _ := reader.FieldPos(0)
+
+			return 0, fmt.Errorf("%w: %q at line %d", ErrDuplicateKey, key, line)
+		}
+
+		lastKey = key
+
+		if err := writer.Add(key, values); err != nil {
+			return 0, fmt.Errorf("writer.Add: %w", err)
+		}
+
+		count++
+	}
+
+	if count == 0 {
+		return 0, errEmptySSTable
+	}
+
+	if err := writer.Close(); err != nil {
+		return 0, fmt.Errorf("writer.Close: %w", err)
+	}
+
+	return count, nil
+}
+
+// sstableColumns returns the column names in the order of sstable rows,
+// the key followed by the values, or nil if the columns are not named.
+func (t *Table) sstableColumns() []string {
+	if t.columns == nil {
+		return nil
+	}
+
+	keyNames := make([]string, len(t.keyColumns))
+	for i, keyColumn := range t.keyColumns {
+		keyNames[i] = t.columns[keyColumn]
+	}
+
+	columns := []string{strings.Join(keyNames, "+")}
+
+	for i, name := range t.columns {
+		if !slices.Contains(t.keyColumns, i) {
+			columns = append(columns, name)
+		}
+	}
+
+	return columns
+}
@@ -0,0 +1,116 @@
+package table_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+func TestSSTable(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "test.csv")
+	sstFile := filepath.Join(dir, "test.sst")
+
+	writeCsv(t, csvFile, "name,id,score", "alice,a,1", "bob,b,2", "carol,c,3", "dave,d,4", "eve,e,5")
+
+	csvOpts := yuccaTable.BuildOptions{Header: true, KeyColumn: 1, IndexInterval: 2}
+	if err := yuccaTable.ConvertToSSTable(csvFile, sstFile, &recordLogger{}, csvOpts); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cache := range []*yuccaTable.BlockCache{nil, yuccaTable.NewBlockCache(1 << 20)} {
+		table, err := yuccaTable.LoadTable(sstFile, &recordLogger{}, yuccaTable.BuildOptions{BlockCache: cache})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer table.Close()
+
+		if _, err := os.Stat(yuccaTable.IndexFile(sstFile)); !os.IsNotExist(err) {
+			t.Fatalf("expected no index file, but got %v", err)
+		}
+
+		if want := []string{"id", "name", "score"}; !reflect.DeepEqual(table.Columns(), want) {
+			t.Fatalf("expected columns %v, but got %v", want, table.Columns())
+		}
+
+		testGet(t, table, "a", []string{"alice", "1"})
+		testGet(t, table, "d", []string{"dave", "4"})
+		testGet(t, table, "e", []string{"eve", "5"})
+		testGet(t, table, "0", nil)
+		testGet(t, table, "cc", nil)
+		testGet(t, table, "f", nil)
+
+		res, err := table.GetColumns("c", []string{"score"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(res.Values, []string{"3"}) {
+			t.Fatalf("unexpected values: %v", res.Values)
+		}
+
+		bulk, err := table.BulkGet([]string{"a", "bb", "e"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(bulk.Values, [][]string{{"alice", "1"}, nil, {"eve", "5"}}) {
+			t.Fatalf("unexpected bulk values: %v", bulk.Values)
+		}
+
+		scan, err := table.ReverseScan("b", "e", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(scan.Keys, []string{"d", "c", "b"}) {
+			t.Fatalf("unexpected scan keys: %v", scan.Keys)
+		}
+	}
+}
+
+func TestSSTableCorrupted(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "test.csv")
+	sstFile := filepath.Join(dir, "test.sst")
+
+	writeCsv(t, csvFile, "a,1", "b,2", "c,3")
+
+	if err := yuccaTable.ConvertToSSTable(csvFile, sstFile, &recordLogger{}, yuccaTable.BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := os.ReadFile(sstFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf[3] ^= 0xff
+	if err := os.WriteFile(sstFile, buf, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the metadata is checked by default
+	table, err := yuccaTable.LoadTable(sstFile, &recordLogger{}, yuccaTable.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.Close()
+
+	_, err = yuccaTable.LoadTable(sstFile, &recordLogger{}, yuccaTable.BuildOptions{Verify: yuccaTable.VerifyChecksum})
+	if err == nil {
+		t.Fatal("expected checksum error")
+	}
+
+	if _, err := yuccaTable.BuildTable(sstFile, &recordLogger{}, yuccaTable.BuildOptions{Header: true}); !errors.Is(err, yuccaTable.ErrUnsupportedOption) {
+		t.Fatalf("expected ErrUnsupportedOption, but got %v", err)
+	}
+}
@@ -1,20 +1,30 @@
 package table
 
 import (
-	"encoding/csv"
+	"bytes"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
+	"slices"
 	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/yokomotod/yuccadb/bgzf"
+	"github.com/yokomotod/yuccadb/internals/bloom"
 	"github.com/yokomotod/yuccadb/internals/humanize"
 	"github.com/yokomotod/yuccadb/logger"
 )
 
 const (
 	defaultIndexInterval = 1_000
+	// defaultKeySeparator sorts before any other character,
+	// so that joined composite keys sort in the same order as their tuples.
+	defaultKeySeparator = "\x00"
+	defaultKeyField     = "key"
 )
 
 type indexEntry struct {
@@ -22,11 +32,37 @@ type indexEntry struct {
 	offset int64
 }
 
+// tableIDs identifies tables in the shared BlockCache.
+var tableIDs atomic.Uint64 //nolint:gochecknoglobals
+
 type Table struct {
+	id            uint64
 	file          string
+	handle        *os.File
+	reader        io.ReaderAt // reads the uncompressed data from handle
+	data          []byte      // mapped data file, nil unless ReadModeMmap
+	cache         *BlockCache
+	bloom         *bloom.Filter
+	opts          BuildOptions
 	index         []indexEntry
+	columns       []string
+	columnIndex   columnIndex
 	timestamp     time.Time
 	indexInterval int64
+	keyColumns    []int
+	maxKeyColumn  int
+	keySeparator  string
+	maxBlockRows  int64 // the most rows between two index entries
+	fields        int   // number of fields of every row, 0 if rows may have any number of fields
+	compressed    bool
+	blocks        []bgzf.Block // blocks of the compressed file, nil unless compressed
+	schema        Schema       // schema of a Parquet file, nil otherwise
+	count         int64
+	size          int64 // uncompressed size of the data
+	fileSize      int64
+	modTime       time.Time
+	checksum      uint32
+	source        fileStat // data file which the sorted file was sorted from, zero unless sorted
 	Logger        logger.Logger
 }
 
@@ -38,36 +74,317 @@ func (t *Table) Timestamp() time.Time {
 	return t.timestamp
 }
 
-func BuildTable(csvFile string, logger logger.Logger) (*Table, error) {
+// ReadMode returns the read mode actually in use, which may differ from the requested one
+// when mapping the file failed.
+func (t *Table) ReadMode() ReadMode {
+	if t.data != nil {
+		return ReadModeMmap
+	}
+
+	return ReadModeFile
+}
+
+// Close closes the data file. The table must not be used after Close.
+func (t *Table) Close() error {
+	if t.cache != nil {
+		t.cache.removeTable(t.id)
+	}
+
+	if t.data != nil {
+		if err := munmap(t.data); err != nil {
+			return err
+		}
+
+		t.data = nil
+	}
+
+	if err := t.handle.Close(); err != nil {
+		return fmt.Errorf("file.Close: %w", err)
+	}
+
+	return nil
+}
+
+// section returns a reader of the data file from offset to limit.
+// It does not move any shared file position, so sections can be read concurrently.
+func (t *Table) section(offset, limit int64) io.Reader {
+	if t.data != nil {
+		return bytes.NewReader(t.data[offset:limit])
+	}
+
+	return io.NewSectionReader(t.reader, offset, limit-offset)
+}
+
+func newTable(file string, logger logger.Logger, opts BuildOptions) (*Table, error) {
+	opts.Format = resolveFormat(file, opts.Format)
+
+	if opts.BloomFalsePositiveRate < 0 || opts.BloomFalsePositiveRate >= 1 {
+		return nil, fmt.Errorf("%w: bloom filter false positive rate %v is not in (0, 1)",
+			ErrInvalidOption, opts.BloomFalsePositiveRate)
+	}
+
+	indexInterval := opts.IndexInterval
+	if indexInterval <= 0 {
+		indexInterval = defaultIndexInterval
+	}
+
+	keyColumns := opts.KeyColumns
+	if len(keyColumns) == 0 {
+		keyColumns = []int{opts.KeyColumn}
+	}
+
+	for i, keyColumn := range keyColumns {
+		if keyColumn < 0 || slices.Contains(keyColumns[:i], keyColumn) {
+			return nil, fmt.Errorf("%w: key columns %v must be distinct and not negative", ErrNoKeyColumn, keyColumns)
+		}
+	}
+
+	keySeparator := opts.KeySeparator
+	if keySeparator == "" {
+		keySeparator = defaultKeySeparator
+	}
+
 	table := &Table{
-		indexInterval: defaultIndexInterval,
+		id:            tableIDs.Add(1),
+		cache:         opts.BlockCache,
+		indexInterval: indexInterval,
+		keyColumns:    keyColumns,
+		maxKeyColumn:  slices.Max(keyColumns),
+		keySeparator:  keySeparator,
+		compressed:    isCompressed(file),
+		opts:          opts,
 		Logger:        logger,
 	}
 
-	err := table.load(csvFile)
+	if !opts.Format.builtin() {
+		return nil, fmt.Errorf("%w: %v is opened by OpenTable", ErrUnknownFormat, opts.Format)
+	}
+
+	if err := table.validateFormat(); err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+func BuildTable(csvFile string, logger logger.Logger, opts BuildOptions) (*Table, error) {
+	table, err := newTable(csvFile, logger, opts)
 	if err != nil {
+		return nil, err
+	}
+
+	switch table.opts.Format { //nolint:exhaustive
+	case FormatSSTable:
+		err = table.loadSSTable(csvFile)
+	case FormatParquet:
+		err = table.loadParquet(csvFile, false)
+	default:
+		err = table.load(csvFile)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("load: %w", err)
+	}
+
+	table.mapData()
+
+	return table, nil
+}
+
+// LoadTable is like BuildTable, but it reuses the index file written next to csvFile
+// when it still matches the data file, and (re)writes it otherwise.
+func LoadTable(csvFile string, logger logger.Logger, opts BuildOptions) (*Table, error) {
+	table, err := newTable(csvFile, logger, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if table.opts.Format == FormatSSTable {
+		// sstable files have their own index
+		if err := table.loadSSTable(csvFile); err != nil {
+			return nil, fmt.Errorf("loadSSTable: %w", err)
+		}
+
+		table.mapData()
+
+		return table, nil
+	}
+
+	if table.opts.Format == FormatParquet {
+		// Parquet files are converted to sstable files, which are reused like index files
+		if err := table.loadParquet(csvFile, opts.Verify != VerifyRebuild); err != nil {
+			return nil, fmt.Errorf("loadParquet: %w", err)
+		}
+
+		table.mapData()
+
+		return table, nil
+	}
+
+	err = errors.New("verify level is rebuild")
+	if opts.Verify != VerifyRebuild {
+		err = table.loadIndex(csvFile)
+	}
+
+	if err == nil {
+		table.mapData()
+
+		return table, nil
+	}
+
+	logger.Debugf("Index file for %q is not usable, rebuilding: %v\n", csvFile, err)
+
+	if err := table.load(csvFile); err != nil {
 		return nil, fmt.Errorf("load: %w", err)
 	}
 
+	if err := table.writeIndex(); err != nil {
+		// the table is still usable without the index file
+		logger.Infof("Failed to write index file for %q: %v\n", csvFile, err)
+	}
+
+	table.mapData()
+
 	return table, nil
 }
 
+// mapData maps the data file into memory if ReadModeMmap is requested.
+func (t *Table) mapData() {
+	if t.opts.ReadMode != ReadModeMmap {
+		return
+	}
+
+	if t.compressed {
+		t.Logger.Infof("Cannot mmap compressed %q, falling back to file reader\n", t.file)
+
+		return
+	}
+
+	data, err := mmap(t.handle, t.size)
+	if err != nil {
+		t.Logger.Infof("Failed to mmap %q, falling back to file reader: %v\n", t.file, err)
+
+		return
+	}
+
+	t.data = data
+}
+
+// load builds the index of the data file. If the data file has bad rows and the BadRows option is set,
+// keys are not sorted and the Sort option is set, or the data file is compressed in too large blocks,
+// it writes the good rows, sorted if needed, to PreparedFile and builds the index of the prepared file instead.
 func (t *Table) load(csvFile string) error {
-	time0 := time.Now()
+	err := t.loadFile(csvFile)
+	if err == nil || !t.mayPrepare() {
+		if err == nil && t.mayPrepare() {
+			t.removePrepared(csvFile)
+		}
+
+		return err
+	}
+
+	unsorted := errors.Is(err, ErrKeysNotSorted)
+	largeBlocks := errors.Is(err, errLargeBlocks)
 
+	if unsorted && !t.opts.Sort || errors.Is(err, ErrDuplicateKey) ||
+		!unsorted && !largeBlocks && t.opts.BadRows == BadRowsFail {
+		return err
+	}
+
+	preparedFile := PreparedFile(csvFile)
+
+	t.Logger.Infof("%q cannot be loaded as it is, preparing %q: %v\n", csvFile, preparedFile, err)
+
+	// rows of large blocks were read to the end, so they are known to be sorted
+	bad, prepareErr := t.prepareFile(csvFile, preparedFile, t.opts.Sort && !largeBlocks)
+	if prepareErr != nil {
+		return fmt.Errorf("prepareFile: %w", prepareErr)
+	}
+
+	if bad == 0 && !unsorted && !largeBlocks {
+		// not a bad row, but e.g. an I/O error
+		t.removePrepared(csvFile)
+
+		return err
+	}
+
+	if err := t.loadFile(preparedFile); err != nil {
+		return err
+	}
+
+	// the table is the data file, and the prepared file is a cache of it
+	t.file = csvFile
+
+	return nil
+}
+
+func (t *Table) loadFile(csvFile string) error {
 	file, err := os.Open(csvFile)
 	if err != nil {
 		return fmt.Errorf("os.Open(%q): %w", csvFile, err)
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	reader.ReuseRecord = true
+	if err := t.buildIndex(file); err != nil {
+		file.Close()
+
+		return err
+	}
+
+	return nil
+}
+
+var (
+	errEmptyTable = errors.New("table has no rows")
+	errBadRow     = errors.New("bad row")
+)
+
+// buildIndex reads the whole file and keeps it open for reads.
+func (t *Table) buildIndex(file *os.File) error {
+	time0 := time.Now()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("file.Stat: %w", err)
+	}
+
+	hash := crc32.NewIEEE()
 
-	var count, lastOffset int64
+	// the checksum is of the file as is, so that it can be verified without decompressing
+	var src io.Reader = io.TeeReader(file, hash)
+
+	var gz *bgzf.Reader
+
+	if t.compressed {
+		gz = bgzf.NewReader(src)
+		src = gz
+	}
+
+	reader := t.newRowReader(src, true)
+
+	var header []string
+
+	if t.opts.Header {
+		cols, err := reader.Read()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("reader.Read: %w", err)
+		}
+
+		header = slices.Clone(cols)
+	}
+
+	if err := t.initColumns(header); err != nil {
+		return err
+	}
+
+	t.fixFields(reader)
+
+	// lastOffset is the offset of the first row of lastKey
+	var count, lastOffset, blockRows, maxBlockRows int64
 
 	var lastKey string
 
+	var buf []string
+
 	index := make([]indexEntry, 0)
 
 	for {
@@ -79,94 +396,218 @@ func (t *Table) load(csvFile string) error {
 				break
 			}
 
-			return fmt.Errorf("csv.Reader.Read: %w", err)
+			return fmt.Errorf("reader.Read at offset %d: %w", offset, err)
 		}
 
-		key := cols[0]
+		if t.opts.Schema != nil {
+			line, _ := reader.FieldPos(0)
+			if err := t.opts.Schema.validateRow(cols, line); err != nil {
+				return err
+			}
+		}
+
+		if t.opts.BadRows != BadRowsFail {
+			// also fail at rows which BadRowsFail loads as they are, so that they are skipped
+			if p := t.validateRow(cols); p != nil {
+				line, _ := reader.FieldPos(0)
+
+				return fmt.Errorf("%w at line %d: %s: %s", errBadRow, line, p.kind, p.message)
+			}
+		}
+
+		key, values, err := t.splitRow(cols, buf)
+		if err != nil {
+			return err
+		}
+
+		buf = values
+
 		if key < lastKey {
-			return fmt.Errorf("keys are not sorted: %q, %q", lastKey, key)
+			return fmt.Errorf("%w: %q, %q", ErrKeysNotSorted, lastKey, key)
+		}
+
+		duplicate := count > 0 && key == lastKey
+
+		if duplicate && t.opts.Duplicates == DuplicateReject {
+			line, _ := reader.FieldPos(0)
+
+			return fmt.Errorf("%w: %q at line %d", ErrDuplicateKey, key, line)
 		}
 
-		if count%t.indexInterval == 0 {
+		// rows with equal keys are never split into blocks, so blocks may exceed the interval
+		if count == 0 || (!duplicate && (blockRows >= t.indexInterval ||
+			(t.opts.IndexBytes > 0 && offset-index[len(index)-1].offset >= t.opts.IndexBytes))) {
 			index = append(index, indexEntry{key, offset})
+			blockRows = 0
+		}
+
+		blockRows++
+		maxBlockRows = max(maxBlockRows, blockRows)
+
+		if !duplicate {
+			lastOffset = offset
 		}
 
-		lastOffset = offset
 		count++
 		lastKey = key
 	}
 
+	if count == 0 {
+		return errEmptyTable
+	}
+
 	// add last key
 	if index[len(index)-1].key != lastKey {
 		index = append(index, indexEntry{lastKey, lastOffset})
 	}
 
-	t.file = csvFile
+	t.size = stat.Size()
+	if gz != nil {
+		t.size = gz.Size()
+		t.blocks = gz.Blocks()
+
+		// every lookup would decompress a whole large block
+		if size := maxBlockSize(t.blocks, t.size); size > maxCompressedBlockSize {
+			return fmt.Errorf("%w: %s bytes", errLargeBlocks, humanize.Comma(size))
+		}
+	}
+
+	t.file = file.Name()
+	t.handle = file
+	t.fileSize = stat.Size()
+	t.reader = t.dataReader(file, t.blocks, t.fileSize, t.size)
 	t.index = index
+	t.maxBlockRows = maxBlockRows
+	t.fields = fieldsPerRecord(reader)
+	t.count = count
+	t.modTime = stat.ModTime()
+	t.checksum = hash.Sum32()
+
+	if err := t.buildBloom(); err != nil {
+		return err
+	}
+
 	t.timestamp = time.Now()
 
-	t.Logger.Infof("Loaded %q with %d items (%v)", csvFile, humanize.Comma(count), t.timestamp.Sub(time0))
+	t.Logger.Infof("Loaded %q with %s items (%v)", t.file, humanize.Comma(count), t.timestamp.Sub(time0))
 
 	return nil
 }
 
 type Profile struct {
 	SearchOffset time.Duration
-	Open         time.Duration
-	Seek         time.Duration
 	Scan         time.Duration
 }
 
 type Result struct {
 	Values  []string
 	Profile Profile
+	columns columnIndex
 }
 
 func (t *Table) Get(key string) (Result, error) {
+	return t.get(key, nil)
+}
+
+func (t *Table) get(key string, p *projection) (Result, error) {
+	if !t.mayContain(key) {
+		return Result{}, nil
+	}
+
+	if t.cache != nil {
+		return t.getCached(key, p)
+	}
+
 	profile := Profile{}
 	time1 := time.Now()
 
-	offset, limit := t.searchIndex(key)
+	offset, _ := t.searchIndex(key)
 
 	time2 := time.Now()
 	profile.SearchOffset = time2.Sub(time1)
 	time1 = time2
 
 	if offset == -1 {
-		return Result{nil, profile}, nil
+		return t.result(nil, profile, p)
 	}
 
-	file, err := os.Open(t.file)
+	scanner := t.newRowScanner(t.section(offset, t.size))
+
+	value, err := t.scanFile(scanner, key)
 	if err != nil {
-		return Result{nil, profile}, fmt.Errorf("os.Open(%q): %w", t.file, err)
+		return Result{}, fmt.Errorf("scanFile: %w", err)
 	}
-	defer file.Close()
 
 	time2 = time.Now()
-	profile.Open = time2.Sub(time1)
-	time1 = time2
+	profile.Scan = time2.Sub(time1)
+
+	return t.result(value, profile, p)
+}
+
+var ErrKeyTuple = errors.New("number of key parts does not match key columns")
+
+// JoinKey joins parts of a composite key in the order of KeyColumns.
+func (t *Table) JoinKey(parts []string) (string, error) {
+	if len(parts) != len(t.keyColumns) {
+		return "", fmt.Errorf("%w: %d parts, but %d key columns", ErrKeyTuple, len(parts), len(t.keyColumns))
+	}
 
-	_, err = file.Seek(offset, 0)
+	return strings.Join(parts, t.keySeparator), nil
+}
+
+// GetTuple is like Get, but takes the parts of a composite key instead of the joined key.
+func (t *Table) GetTuple(parts []string) (Result, error) {
+	key, err := t.JoinKey(parts)
 	if err != nil {
-		return Result{nil, profile}, fmt.Errorf("file.Seek: %w", err)
+		return Result{}, err
 	}
 
-	time2 = time.Now()
-	profile.Seek = time2.Sub(time1)
-	time1 = time2
+	return t.get(key, nil)
+}
+
+// buildBloom builds the bloom filter of keys if BloomFalsePositiveRate is set.
+// It reads the keys again after the index is built, since the filter is sized by the number of rows,
+// and keeping the keys or their hashes until then would take much more memory than the filter.
+func (t *Table) buildBloom() error {
+	if t.opts.BloomFalsePositiveRate <= 0 {
+		return nil
+	}
 
-	reader := csv.NewReader(file)
-	reader.ReuseRecord = true
+	filter := bloom.New(t.count, t.opts.BloomFalsePositiveRate)
 
-	value, err := t.scanFile(reader, key, limit-offset)
-	if err != nil {
-		return Result{nil, profile}, fmt.Errorf("scanFile: %w", err)
+	// the first index entry skips the header
+	reader := t.newRowReader(t.section(t.index[0].offset, t.size), true)
+
+	var buf []string
+
+	for {
+		cols, err := reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return fmt.Errorf("reader.Read: %w", err)
+		}
+
+		key, values, err := t.splitRow(cols, buf)
+		if err != nil {
+			return err
+		}
+
+		buf = values
+
+		filter.Add(key)
 	}
 
-	time2 = time.Now()
-	profile.Scan = time2.Sub(time1)
+	t.bloom = filter
+
+	return nil
+}
 
-	return Result{value, profile}, nil
+// mayContain reports false if key is definitely not in the table.
+func (t *Table) mayContain(key string) bool {
+	return t.bloom == nil || t.bloom.Test(key)
 }
 
 func (t *Table) searchIndex(key string) (offset, limit int64) {
@@ -192,59 +633,39 @@ func (t *Table) searchIndex(key string) (offset, limit int64) {
 	return t.index[idx-1].offset, t.index[idx].offset
 }
 
-func (t *Table) scanFile(reader *csv.Reader, key string, limit int64) ([]string, error) {
-	var scannedLines int64
-
-	for {
-		cols, err := reader.Read()
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				break
-			}
-
-			return nil, fmt.Errorf("csv.Reader.Read: %w", err)
-		}
-
-		if cols[0] == key {
-			// return copy due to ReuseRecord
-			dup := make([]string, len(cols)-1)
-			copy(dup, cols[1:])
-
-			return dup, nil
-		}
-
-		scannedLines++
-
-		if reader.InputOffset() >= limit {
-			// reached to next index, means not found
-			return nil, nil
-		}
-
-		if scannedLines > t.indexInterval {
-			// should never happen
-			return nil, fmt.Errorf("too many scanned lines: %d", scannedLines)
-		}
+func copyValues(values []string) []string {
+	if values == nil {
+		return nil
 	}
 
-	// should never happen, last key should be in index
-	return nil, errors.New("should never reach here")
+	dup := make([]string, len(values))
+	copy(dup, values)
+
+	return dup
 }
 
 type BulkResult struct {
 	Values [][]string
 }
 
-var ErrKeysNotSorted = errors.New("keys are not sorted")
+var (
+	ErrKeysNotSorted = errors.New("keys are not sorted")
+	ErrDuplicateKey  = errors.New("duplicate key")
+)
 
 func (t *Table) BulkGet(keys []string) (BulkResult, error) {
+	return t.bulkGet(keys, nil)
+}
+
+func (t *Table) bulkGet(keys []string, p *projection) (BulkResult, error) {
 	if len(keys) == 0 {
 		return BulkResult{}, errors.New("no keys")
 	}
 
 	if len(keys) == 1 {
-		res, err := t.Get(keys[0])
+		res, err := t.get(keys[0], p)
 		if err != nil {
-			return BulkResult{}, fmt.Errorf("Get: %w", err)
+			return BulkResult{}, fmt.Errorf("get: %w", err)
 		}
 
 		return BulkResult{[][]string{res.Values}}, nil
@@ -254,37 +675,30 @@ func (t *Table) BulkGet(keys []string) (BulkResult, error) {
 		return BulkResult{}, ErrKeysNotSorted
 	}
 
-	chunks := t.bulkSearchIndices(keys)
-	if chunks == nil {
+	if keys[len(keys)-1] < t.index[0].key || t.index[len(t.index)-1].key < keys[0] {
 		// all keys are out of range
 		return BulkResult{make([][]string, len(keys))}, nil
 	}
 
-	file, err := os.Open(t.file)
+	it, err := t.BulkIter(keys)
 	if err != nil {
-		return BulkResult{}, fmt.Errorf("os.Open(%q): %w", t.file, err)
+		return BulkResult{}, fmt.Errorf("BulkIter: %w", err)
 	}
-	defer file.Close()
+	defer it.Close()
 
 	values := make([][]string, 0, len(keys))
 
-	for _, chunk := range chunks {
-		_, err = file.Seek(chunk.offset, 0)
+	for it.Next() {
+		projected, err := p.project(it.Values())
 		if err != nil {
-			return BulkResult{}, fmt.Errorf("file.Seek: %w", err)
+			return BulkResult{}, fmt.Errorf("project: %w", err)
 		}
 
-		reader := csv.NewReader(file)
-		reader.ReuseRecord = true
-
-		for _, key := range chunk.keys {
-			value, err := t.scanFile(reader, key, chunk.limit-chunk.offset)
-			if err != nil {
-				return BulkResult{}, fmt.Errorf("scanFile: %w", err)
-			}
+		values = append(values, projected)
+	}
 
-			values = append(values, value)
-		}
+	if err := it.Err(); err != nil {
+		return BulkResult{}, err
 	}
 
 	return BulkResult{values}, nil
@@ -296,13 +710,9 @@ type bulkSearchChunk struct {
 	limit  int64
 }
 
-// keys must be sorted and more than 2.
+// keys must be sorted and not empty.
+// Keys out of range are grouped into chunks with offset -1.
 func (t *Table) bulkSearchIndices(keys []string) []*bulkSearchChunk {
-	if keys[len(keys)-1] < t.index[0].key || t.index[len(t.index)-1].key < keys[0] {
-		// all keys are out of range
-		return nil
-	}
-
 	offset, limit := t.searchIndex(keys[0])
 
 	lastChunk := &bulkSearchChunk{keys: []string{keys[0]}, offset: offset, limit: limit}
@@ -0,0 +1,610 @@
+package table_test
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+func TestReadModeMmap(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("mmap is not supported")
+	}
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, testFile, "a,1", "b,2", "c,3")
+
+	table, err := yuccaTable.BuildTable(testFile, &recordLogger{}, yuccaTable.BuildOptions{ReadMode: yuccaTable.ReadModeMmap})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	if table.ReadMode() != yuccaTable.ReadModeMmap {
+		t.Fatalf("expected read mode %v, but got %v", yuccaTable.ReadModeMmap, table.ReadMode())
+	}
+
+	testGet(t, table, "a", []string{"1"})
+	testGet(t, table, "c", []string{"3"})
+	testGet(t, table, "d", nil)
+}
+
+func TestBloomFilter(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, testFile, "a,1", "b,2", "d,4")
+
+	opts := yuccaTable.BuildOptions{BloomFalsePositiveRate: 0.01}
+
+	for _, wantLog := range []string{"rebuilding", "from index file"} {
+		logger := &recordLogger{}
+
+		table, err := yuccaTable.LoadTable(testFile, logger, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !logger.contains(wantLog) {
+			t.Fatalf("expected log %q, but got logs %q", wantLog, logger.messages)
+		}
+
+		testGet(t, table, "a", []string{"1"})
+		testGet(t, table, "d", []string{"4"})
+		testGet(t, table, "c", nil)
+
+		res, err := table.BulkGet([]string{"a", "c", "d"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(res.Values, [][]string{{"1"}, nil, {"4"}}) {
+			t.Fatalf("unexpected values: %v", res.Values)
+		}
+
+		table.Close()
+	}
+
+	// the index file is rebuilt when the bloom filter setting changes
+	logger := &recordLogger{}
+
+	table, err := yuccaTable.LoadTable(testFile, logger, yuccaTable.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	if !logger.contains("rebuilding") {
+		t.Fatalf("expected to rebuild index, but got logs %q", logger.messages)
+	}
+
+	for _, rate := range []float64{-0.1, 1, 1.5} {
+		_, err := yuccaTable.BuildTable(testFile, &recordLogger{}, yuccaTable.BuildOptions{BloomFalsePositiveRate: rate})
+		if !errors.Is(err, yuccaTable.ErrInvalidOption) {
+			t.Fatalf("expected %v for rate %v, but got %v", yuccaTable.ErrInvalidOption, rate, err)
+		}
+	}
+}
+
+func TestBloomFilterKeyColumn(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, testFile, "name,key", "alice,a", "bob,b", "dave,d")
+
+	opts := yuccaTable.BuildOptions{BloomFalsePositiveRate: 0.01, Header: true, KeyColumn: 1, IndexInterval: 2}
+
+	table, err := yuccaTable.BuildTable(testFile, &recordLogger{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	testGet(t, table, "a", []string{"alice"})
+	testGet(t, table, "b", []string{"bob"})
+	testGet(t, table, "d", []string{"dave"})
+	testGet(t, table, "key", nil)
+}
+
+func TestBuildOptions(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.psv")
+	writeCsv(t, testFile, "name|key|score", "alice|a|1", "bob|b|2", "carol|c|3", "dave|d|4", "eve|e|5")
+
+	opts := yuccaTable.BuildOptions{IndexInterval: 2, Delimiter: '|', KeyColumn: 1, Header: true}
+
+	table, err := yuccaTable.BuildTable(testFile, &recordLogger{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	testGet(t, table, "a", []string{"alice", "1"})
+	testGet(t, table, "d", []string{"dave", "4"})
+	testGet(t, table, "e", []string{"eve", "5"})
+	testGet(t, table, "key", nil)
+
+	res, err := table.Scan("b", "d", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(res.Keys, []string{"b", "c"}) ||
+		!reflect.DeepEqual(res.Values, [][]string{{"bob", "2"}, {"carol", "3"}}) {
+		t.Fatalf("unexpected scan result: %v", res)
+	}
+
+	bulk, err := table.BulkGet([]string{"a", "c", "cc", "e"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(bulk.Values, [][]string{{"alice", "1"}, {"carol", "3"}, nil, {"eve", "5"}}) {
+		t.Fatalf("unexpected bulk result: %v", bulk.Values)
+	}
+}
+
+func TestEmptyTable(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		file    string
+		content string
+		opts    yuccaTable.BuildOptions
+	}{
+		{"header only", "test.csv", "k,v\n", yuccaTable.BuildOptions{Header: true}},
+		{"empty csv", "test.csv", "", yuccaTable.BuildOptions{}},
+		{"empty jsonl", "test.jsonl", "", yuccaTable.BuildOptions{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			testFile := filepath.Join(t.TempDir(), c.file)
+			if err := os.WriteFile(testFile, []byte(c.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := yuccaTable.LoadTable(testFile, &recordLogger{}, c.opts)
+			if err == nil || !strings.Contains(err.Error(), "no rows") {
+				t.Fatalf("expected an error of no rows, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestIndexBytes(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+
+	// a few very wide rows among narrow ones
+	lines := make([]string, 0, 100)
+	for i := range 100 {
+		value := strconv.Itoa(i)
+		if i%10 == 0 {
+			value = strings.Repeat("x", 1_000)
+		}
+
+		lines = append(lines, fmt.Sprintf("%03d,%s", i, value))
+	}
+
+	writeCsv(t, testFile, lines...)
+
+	opts := yuccaTable.BuildOptions{IndexInterval: 1_000, IndexBytes: 256}
+
+	for _, load := range []string{"build", "index file"} {
+		logger := &recordLogger{}
+
+		table, err := yuccaTable.LoadTable(testFile, logger, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if load == "index file" && !logger.contains("from index file") {
+			t.Fatalf("expected to load from index file, but got logs %q", logger.messages)
+		}
+
+		for i := range 100 {
+			want := strconv.Itoa(i)
+			if i%10 == 0 {
+				want = strings.Repeat("x", 1_000)
+			}
+
+			testGet(t, table, fmt.Sprintf("%03d", i), []string{want})
+		}
+
+		testGet(t, table, "0055", nil)
+
+		res, err := table.Scan("015", "025", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(res.Keys) != 10 || res.Keys[0] != "015" || res.Keys[9] != "024" {
+			t.Fatalf("unexpected scan result: %v", res.Keys)
+		}
+
+		table.Close()
+	}
+
+	// index files built with other spacing are not reused
+	logger := &recordLogger{}
+
+	table, err := yuccaTable.LoadTable(testFile, logger, yuccaTable.BuildOptions{IndexInterval: 1_000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	if !logger.contains("index bytes") {
+		t.Fatalf("expected to rebuild index, but got logs %q", logger.messages)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, testFile, "name,id,score", "alice,a,1", "bob,b,2")
+
+	cases := []struct {
+		name string
+		opts yuccaTable.BuildOptions
+		want []string
+	}{
+		{"header", yuccaTable.BuildOptions{KeyColumn: 1, Header: true}, []string{"name", "id", "score"}},
+		{"explicit", yuccaTable.BuildOptions{KeyColumn: 1, Header: true, Columns: []string{"n", "k", "s"}}, []string{"n", "k", "s"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			// build, then load from the index file
+			for range 2 {
+				table, err := yuccaTable.LoadTable(testFile, &recordLogger{}, c.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer table.Close()
+
+				if !reflect.DeepEqual(table.Columns(), c.want) {
+					t.Fatalf("expected columns %v, but got %v", c.want, table.Columns())
+				}
+
+				res, err := table.Get("b")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if v, ok := res.Get(c.want[2]); !ok || v != "2" {
+					t.Fatalf("expected %q, but got %q, %v", "2", v, ok)
+				}
+
+				if _, ok := res.Get(c.want[1]); ok {
+					t.Fatal("expected key column not to be a value")
+				}
+
+				if want := map[string]string{c.want[0]: "bob", c.want[2]: "2"}; !reflect.DeepEqual(res.Map(), want) {
+					t.Fatalf("expected %v, but got %v", want, res.Map())
+				}
+			}
+		})
+	}
+}
+
+func TestColumnsWithoutNames(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, testFile, "a,1", "b,2")
+
+	table, err := yuccaTable.BuildTable(testFile, &recordLogger{}, yuccaTable.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	if table.Columns() != nil {
+		t.Fatalf("expected no columns, but got %v", table.Columns())
+	}
+
+	res, err := table.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := res.Get("1"); ok || res.Map() != nil {
+		t.Fatal("expected no values by names")
+	}
+
+	_, err = yuccaTable.BuildTable(testFile, &recordLogger{}, yuccaTable.BuildOptions{Columns: []string{"k", "v", "k"}})
+	if !errors.Is(err, yuccaTable.ErrDuplicateColumn) {
+		t.Fatalf("expected ErrDuplicateColumn, but got %v", err)
+	}
+}
+
+func TestFieldCount(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		lines   []string
+		opts    yuccaTable.BuildOptions
+		want    int
+		wantErr bool
+	}{
+		{"first row", []string{"a,1,x", "b,2,y"}, yuccaTable.BuildOptions{}, 3, false},
+		{"ragged", []string{"a,1,x", "b,2"}, yuccaTable.BuildOptions{}, 0, true},
+		{"header", []string{"k,v", "a,1,x"}, yuccaTable.BuildOptions{Header: true}, 0, true},
+		{"columns", []string{"a,1", "b,2"}, yuccaTable.BuildOptions{Columns: []string{"k", "v"}}, 2, false},
+		{"too few columns", []string{"a,1,x", "b,2,y"}, yuccaTable.BuildOptions{Columns: []string{"k", "v"}}, 0, true},
+		{"jsonl", nil, yuccaTable.BuildOptions{Format: yuccaTable.FormatJSONL}, 0, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			file := filepath.Join(t.TempDir(), "test.csv")
+			if c.lines != nil {
+				writeCsv(t, file, c.lines...)
+			} else {
+				writeCsv(t, file, `{"key":"a","v":1}`, `{"key":"b"}`)
+			}
+
+			// build, then load from the index file
+			for range 2 {
+				table, err := yuccaTable.LoadTable(file, &recordLogger{}, c.opts)
+				if c.wantErr {
+					if !errors.Is(err, csv.ErrFieldCount) {
+						t.Fatalf("expected ErrFieldCount, but got %v", err)
+					}
+
+					return
+				}
+
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer table.Close()
+
+				if got := table.Stats().Fields; got != c.want {
+					t.Fatalf("expected %d fields, but got %d", c.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFieldCountIndexFile(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, file, "a,1,x", "b,2,y")
+
+	// writes the index file with 3 fields
+	table, err := yuccaTable.LoadTable(file, &recordLogger{}, yuccaTable.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.Close()
+
+	logger := &recordLogger{}
+
+	if _, err := yuccaTable.LoadTable(file, logger, yuccaTable.BuildOptions{Columns: []string{"k", "v"}}); !errors.Is(err, csv.ErrFieldCount) {
+		t.Fatalf("expected ErrFieldCount, but got %v", err)
+	}
+
+	if !logger.contains("3 fields, but 2 columns") {
+		t.Fatalf("expected index mismatch, but got logs %q", logger.messages)
+	}
+
+	table, err = yuccaTable.LoadTable(file, &recordLogger{}, yuccaTable.BuildOptions{Columns: []string{"k", "v", "w"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	if got := table.Stats().Fields; got != 3 {
+		t.Fatalf("expected 3 fields, but got %d", got)
+	}
+}
+
+func TestProjection(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, testFile, "id,name,score,note", "a,alice,1,x", "b,bob,2,y", "c,carol,3,z")
+
+	for _, cache := range []*yuccaTable.BlockCache{nil, yuccaTable.NewBlockCache(1 << 20)} {
+		opts := yuccaTable.BuildOptions{Header: true, IndexInterval: 2, BlockCache: cache}
+
+		table, err := yuccaTable.BuildTable(testFile, &recordLogger{}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer table.Close()
+
+		res, err := table.GetColumns("b", []string{"score", "name"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(res.Values, []string{"2", "bob"}) {
+			t.Fatalf("unexpected values: %v", res.Values)
+		}
+
+		if v, ok := res.Get("name"); !ok || v != "bob" {
+			t.Fatalf("expected %q, but got %q, %v", "bob", v, ok)
+		}
+
+		if _, ok := res.Get("note"); ok {
+			t.Fatal("expected a column out of the projection not to be found")
+		}
+
+		bulk, err := table.BulkGetColumns([]string{"a", "bb", "c"}, []string{"note"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(bulk.Values, [][]string{{"x"}, nil, {"z"}}) {
+			t.Fatalf("unexpected bulk values: %v", bulk.Values)
+		}
+
+		scan, err := table.ScanColumns("b", "", 0, []string{"name"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(scan.Values, [][]string{{"bob"}, {"carol"}}) {
+			t.Fatalf("unexpected scan values: %v", scan.Values)
+		}
+
+		if _, err := table.GetColumns("a", []string{"missing"}); !errors.Is(err, yuccaTable.ErrUnknownColumn) {
+			t.Fatalf("expected ErrUnknownColumn, but got %v", err)
+		}
+	}
+}
+
+func TestCompositeKey(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, testFile,
+		"tenant,name,user,score",
+		"t1,alice,u1,1",
+		"t1,bob,u2,2",
+		"t2,carol,u1,3",
+	)
+
+	opts := yuccaTable.BuildOptions{Header: true, KeyColumns: []int{0, 2}, KeySeparator: "#"}
+
+	table, err := yuccaTable.LoadTable(testFile, &recordLogger{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	testGet(t, table, "t1#u2", []string{"bob", "2"})
+	testGet(t, table, "t2#u1", []string{"carol", "3"})
+	testGet(t, table, "t2#u2", nil)
+
+	res, err := table.GetTuple([]string{"t1", "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := res.Get("score"); !ok || v != "1" {
+		t.Fatalf("expected %q, but got %q, %v", "1", v, ok)
+	}
+
+	if _, err := table.GetTuple([]string{"t1"}); !errors.Is(err, yuccaTable.ErrKeyTuple) {
+		t.Fatalf("expected ErrKeyTuple, but got %v", err)
+	}
+
+	it, err := table.PrefixScan("t1#")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	var keys []string
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+
+	if !reflect.DeepEqual(keys, []string{"t1#u1", "t1#u2"}) {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+}
+
+func TestInvalidKeyColumns(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, testFile, "a,1,x", "b,2,y")
+
+	for _, opts := range []yuccaTable.BuildOptions{
+		{KeyColumn: -1},
+		{KeyColumns: []int{0, -1}},
+		{KeyColumns: []int{1, 1}},
+	} {
+		if _, err := yuccaTable.BuildTable(testFile, &recordLogger{}, opts); !errors.Is(err, yuccaTable.ErrNoKeyColumn) {
+			t.Fatalf("expected %v for %+v, but got %v", yuccaTable.ErrNoKeyColumn, opts, err)
+		}
+	}
+}
+
+func TestCompositeKeyDefaultSeparator(t *testing.T) {
+	t.Parallel()
+
+	// sorted by (key1, key2), where "a" < "a!" but "a#z" > "a!#a"
+	testFile := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, testFile, "a,z,1", "a!,a,2")
+
+	table, err := yuccaTable.BuildTable(testFile, &recordLogger{}, yuccaTable.BuildOptions{KeyColumns: []int{0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	res, err := table.GetTuple([]string{"a!", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(res.Values, []string{"2"}) {
+		t.Fatalf("unexpected values: %v", res.Values)
+	}
+
+	opts := yuccaTable.BuildOptions{KeyColumns: []int{0, 1}, KeySeparator: "#"}
+	if _, err := yuccaTable.BuildTable(testFile, &recordLogger{}, opts); err == nil {
+		t.Fatal("expected keys not sorted with the separator")
+	}
+}
+
+func TestCSVOptions(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.tsv")
+	writeCsv(t, testFile,
+		"# exported at 2024-01-01",
+		"a\t  1\tsay \"hi\"",
+		"# a comment between rows",
+		"b\t  2\tplain",
+	)
+
+	opts := yuccaTable.BuildOptions{Delimiter: '\t', Comment: '#', LazyQuotes: true, TrimLeadingSpace: true}
+
+	// build, then load from the index file
+	for range 2 {
+		table, err := yuccaTable.LoadTable(testFile, &recordLogger{}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer table.Close()
+
+		testGet(t, table, "a", []string{"1", `say "hi"`})
+		testGet(t, table, "b", []string{"2", "plain"})
+	}
+
+	if _, err := yuccaTable.BuildTable(testFile, &recordLogger{}, yuccaTable.BuildOptions{Delimiter: '\t'}); err == nil {
+		t.Fatal("expected an error without LazyQuotes")
+	}
+}
@@ -0,0 +1,359 @@
+package table
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yokomotod/yuccadb/bgzf"
+	"github.com/yokomotod/yuccadb/internals/humanize"
+	"github.com/yokomotod/yuccadb/logger"
+)
+
+// ProblemKind classifies the problems found by Validate.
+type ProblemKind int
+
+const (
+	// ProblemMalformed is a row which cannot be parsed, e.g. with a bare or unterminated quote in CSV,
+	// or invalid JSON.
+	ProblemMalformed ProblemKind = iota
+	// ProblemFieldCount is a CSV row with a different number of fields than the Columns option,
+	// or else than the first row, which may be the header.
+	ProblemFieldCount
+	// ProblemInvalidUTF8 is a row with a value which is not valid UTF-8.
+	ProblemInvalidUTF8
+	// ProblemInvalidValue is a row which does not match the Schema option.
+	ProblemInvalidValue
+	// ProblemNoKey is a row without the key column or the key field.
+	ProblemNoKey
+	// ProblemUnsorted is a row whose key is less than the key of the previous row.
+	ProblemUnsorted
+	// ProblemDuplicateKey is a row whose key equals the key of the previous row, only for DuplicateReject.
+	ProblemDuplicateKey
+)
+
+func (k ProblemKind) String() string {
+	switch k {
+	case ProblemMalformed:
+		return "malformed"
+	case ProblemFieldCount:
+		return "field count"
+	case ProblemInvalidUTF8:
+		return "invalid UTF-8"
+	case ProblemInvalidValue:
+		return "invalid value"
+	case ProblemNoKey:
+		return "no key"
+	case ProblemUnsorted:
+		return "unsorted"
+	case ProblemDuplicateKey:
+		return "duplicate key"
+	default:
+		return "unknown"
+	}
+}
+
+// bad reports whether rows with the problem are removed by BadRowsSkip and BadRowsQuarantine.
+// Unsorted and duplicate keys are left to the Sort and Duplicates options.
+func (k ProblemKind) bad() bool {
+	return k != ProblemUnsorted && k != ProblemDuplicateKey
+}
+
+// Problem is a problem of a row.
+type Problem struct {
+	Kind ProblemKind
+	// Line is the 1-based line where the row starts.
+	Line int
+	// Offset is the byte offset where the row starts in the uncompressed data.
+	Offset  int64
+	Message string
+
+	end int64 // offset where the row ends
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("line %d (offset %d): %s: %s", p.Line, p.Offset, p.Kind, p.Message)
+}
+
+// maxReportedProblems bounds the memory of reports of badly broken files. Counts are never truncated.
+const maxReportedProblems = 10_000
+
+// Report is the result of Validate.
+type Report struct {
+	// Rows is the number of rows excluding the header, including bad rows.
+	Rows int64
+	// BadRows is the number of rows which BadRowsSkip would remove.
+	BadRows int64
+	// Counts is the number of problems by kind.
+	Counts map[ProblemKind]int64
+	// Problems are the problems in file order, up to the first 10,000.
+	Problems []Problem
+	// Truncated reports whether Problems omits some problems.
+	Truncated bool
+}
+
+// OK reports whether no problems were found.
+func (r Report) OK() bool {
+	return len(r.Counts) == 0
+}
+
+// Summary returns a line such as "1,000 rows, 2 bad rows: 1 malformed, 1 field count, 3 unsorted".
+func (r Report) Summary() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s rows, %s bad rows", humanize.Comma(r.Rows), humanize.Comma(r.BadRows))
+
+	sep := ": "
+
+	for kind := ProblemMalformed; kind <= ProblemDuplicateKey; kind++ {
+		if n := r.Counts[kind]; n > 0 {
+			fmt.Fprintf(&b, "%s%s %s", sep, humanize.Comma(n), kind)
+			sep = ", "
+		}
+	}
+
+	return b.String()
+}
+
+func (r *Report) add(p Problem) {
+	if r.Counts == nil {
+		r.Counts = make(map[ProblemKind]int64)
+	}
+
+	r.Counts[p.Kind]++
+
+	if len(r.Problems) < maxReportedProblems {
+		r.Problems = append(r.Problems, p)
+	} else {
+		r.Truncated = true
+	}
+}
+
+// Validate reads the whole data file and reports all problems of its rows,
+// instead of failing at the first one as BuildTable does.
+// opts describes the file as for BuildTable. Only FormatCSV and FormatJSONL files are supported.
+// The error is only for failures to read the file.
+func Validate(file string, opts BuildOptions) (Report, error) {
+	t, err := newTable(file, &logger.DefaultLogger{Level: logger.Warning}, opts)
+	if err != nil {
+		return Report{}, err
+	}
+
+	if t.opts.Format != FormatCSV && t.opts.Format != FormatJSONL {
+		return Report{}, fmt.Errorf("%w: validating %s", ErrUnsupportedOption, t.opts.Format)
+	}
+
+	var report Report
+
+	if err := t.readData(file, func(data io.Reader) error {
+		rows, err := t.validateRows(data, func(p Problem) error {
+			report.add(p)
+
+			if p.Kind.bad() {
+				report.BadRows++
+			}
+
+			return nil
+		}, nil)
+		report.Rows = rows
+
+		return err
+	}); err != nil {
+		return Report{}, err
+	}
+
+	return report, nil
+}
+
+// validateRows reads all rows and calls problem for each problem in file order,
+// and row if not nil for each row which is not bad, after the problems of the row.
+// Rows span from offset to end, including comment lines before them. It stops at the first error of the callbacks.
+// It returns the number of rows excluding the header.
+func (t *Table) validateRows(
+	data io.Reader, problem func(Problem) error, row func(key string, offset, end int64) error,
+) (int64, error) {
+	reader := t.newRowReader(data, true)
+
+	var (
+		rows    int64
+		lastKey string
+		hasLast bool
+		buf     []string
+		header  = t.opts.Header
+	)
+
+	if !header {
+		t.fixFields(reader)
+	}
+
+	for {
+		offset := reader.InputOffset()
+
+		cols, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+
+		end := reader.InputOffset()
+
+		if err != nil {
+			var parseErr *csv.ParseError
+
+			isParseErr := errors.As(err, &parseErr)
+			if !isParseErr && end == offset {
+				// nothing was consumed, so it is not a problem of a row
+				return 0, fmt.Errorf("reader.Read: %w", err)
+			}
+
+			p := Problem{Kind: ProblemMalformed, Offset: offset, Message: err.Error(), end: end}
+
+			switch {
+			case isParseErr:
+				p.Line = parseErr.StartLine
+				if errors.Is(err, csv.ErrFieldCount) {
+					p.Kind = ProblemFieldCount
+					p.Message = fmt.Sprintf("%d fields, but the table has %d", len(cols), fieldsPerRecord(reader))
+				}
+			default:
+				p.Line, _ = reader.FieldPos(0)
+				if errors.Is(err, ErrNoKeyField) {
+					p.Kind = ProblemNoKey
+				}
+			}
+
+			if header {
+				header = false
+				t.fixFields(reader)
+			} else {
+				rows++
+			}
+
+			if err := problem(p); err != nil {
+				return 0, err
+			}
+
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		if header {
+			header = false
+			t.fixFields(reader)
+
+			continue
+		}
+
+		rows++
+
+		p := Problem{Line: line, Offset: offset, end: end}
+
+		if rowProblem := t.validateRow(cols); rowProblem != nil {
+			p.Kind, p.Message = rowProblem.kind, rowProblem.message
+			if err := problem(p); err != nil {
+				return 0, err
+			}
+
+			continue
+		}
+
+		key, values, err := t.splitRow(cols, buf)
+		if err != nil {
+			p.Kind, p.Message = ProblemNoKey, err.Error()
+			if err := problem(p); err != nil {
+				return 0, err
+			}
+
+			continue
+		}
+
+		buf = values
+
+		switch {
+		case hasLast && key < lastKey:
+			p.Kind, p.Message = ProblemUnsorted, fmt.Sprintf("%q after %q", key, lastKey)
+			err = problem(p)
+		case hasLast && key == lastKey && t.opts.Duplicates == DuplicateReject:
+			p.Kind, p.Message = ProblemDuplicateKey, fmt.Sprintf("%q", key)
+			err = problem(p)
+		}
+
+		if err == nil && row != nil {
+			err = row(key, offset, end)
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		lastKey, hasLast = key, true
+	}
+}
+
+type rowProblem struct {
+	kind    ProblemKind
+	message string
+}
+
+// validateRow checks the values of a parsed row.
+func (t *Table) validateRow(cols []string) *rowProblem {
+	for i, col := range cols {
+		if !utf8.ValidString(col) {
+			return &rowProblem{ProblemInvalidUTF8, fmt.Sprintf("field %d: %q", i+1, col)}
+		}
+	}
+
+	if t.opts.Format == FormatJSONL && !json.Valid([]byte(cols[1])) {
+		return &rowProblem{ProblemMalformed, "invalid JSON"}
+	}
+
+	if t.opts.Schema != nil {
+		if message := t.opts.Schema.check(cols); message != "" {
+			return &rowProblem{ProblemInvalidValue, message}
+		}
+	}
+
+	return nil
+}
+
+// quarantineFile returns the QuarantineFile option or its default.
+func (t *Table) quarantineFile(file string) string {
+	if t.opts.QuarantineFile == "" {
+		return file + ".rejected"
+	}
+
+	return t.opts.QuarantineFile
+}
+
+// readData calls read with the uncompressed data of the file.
+func (t *Table) readData(file string, read func(data io.Reader) error) error {
+	src, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("os.Open(%q): %w", file, err)
+	}
+	defer src.Close()
+
+	var data io.Reader = src
+	if t.compressed {
+		data = bgzf.NewReader(src)
+	}
+
+	return read(data)
+}
+
+// badRowsFormats are the formats which support the BadRows option.
+var badRowsFormats = []Format{FormatCSV, FormatJSONL} //nolint:gochecknoglobals
+
+func (t *Table) validateBadRows() error {
+	if t.opts.BadRows != BadRowsFail && !slices.Contains(badRowsFormats, t.opts.Format) {
+		return fmt.Errorf("%w: bad rows option for %s", ErrUnsupportedOption, t.opts.Format)
+	}
+
+	return nil
+}
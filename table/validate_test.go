@@ -0,0 +1,257 @@
+package table_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/yokomotod/yuccadb/bgzf"
+	yuccaTable "github.com/yokomotod/yuccadb/table"
+)
+
+// brokenCsv has one problem of each kind, and good rows a, c, d, f and g.
+var brokenCsv = []string{ //nolint:gochecknoglobals
+	"key,value",
+	"a,1",
+	`b,x"y`,
+	"c,3",
+	"c2,3,extra",
+	"d,4",
+	"e,\xff",
+	"c3,5",
+	"f,6",
+	"f,7",
+	"g,8",
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "test.csv")
+	writeCsv(t, file, brokenCsv...)
+
+	report, err := yuccaTable.Validate(file, yuccaTable.BuildOptions{Header: true, Duplicates: yuccaTable.DuplicateReject})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type problem struct {
+		kind   yuccaTable.ProblemKind
+		line   int
+		offset int64
+	}
+
+	var got []problem
+	for _, p := range report.Problems {
+		got = append(got, problem{p.Kind, p.Line, p.Offset})
+	}
+
+	want := []problem{
+		{yuccaTable.ProblemMalformed, 3, 14},
+		{yuccaTable.ProblemFieldCount, 5, 24},
+		{yuccaTable.ProblemInvalidUTF8, 7, 39},
+		{yuccaTable.ProblemUnsorted, 8, 43},
+		{yuccaTable.ProblemDuplicateKey, 10, 52},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected problems %v, but got %v", want, report.Problems)
+	}
+
+	if report.Rows != 10 || report.BadRows != 3 || report.OK() {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	if want := "10 rows, 3 bad rows: 1 malformed, 1 field count, 1 invalid UTF-8, 1 unsorted, 1 duplicate key"; report.Summary() != want {
+		t.Fatalf("expected summary %q, but got %q", want, report.Summary())
+	}
+
+	large := yuccaTable.Report{Rows: 1_234_567, BadRows: 1_000, Counts: map[yuccaTable.ProblemKind]int64{yuccaTable.ProblemMalformed: 1_000}}
+	if want := "1,234,567 rows, 1,000 bad rows: 1,000 malformed"; large.Summary() != want {
+		t.Fatalf("expected summary %q, but got %q", want, large.Summary())
+	}
+
+	// the same problem fails BuildTable
+	if _, err := yuccaTable.BuildTable(file, &recordLogger{}, yuccaTable.BuildOptions{Header: true}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestValidateJSONL(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "test.jsonl")
+	if err := os.WriteFile(file, []byte("{\"key\":\"a\"}\n{\"id\":\"b\"}\n\n{\"key\":\"c\",\n{\"key\":\"d\"}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := yuccaTable.Validate(file, yuccaTable.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds []yuccaTable.ProblemKind
+	for _, p := range report.Problems {
+		kinds = append(kinds, p.Kind)
+	}
+
+	if want := []yuccaTable.ProblemKind{yuccaTable.ProblemNoKey, yuccaTable.ProblemMalformed}; !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("expected problems %v, but got %v", want, report.Problems)
+	}
+
+	if report.Problems[1].Line != 4 || report.Rows != 4 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	if _, err := yuccaTable.Validate(file, yuccaTable.BuildOptions{Format: yuccaTable.FormatSSTable}); !errors.Is(err, yuccaTable.ErrUnsupportedOption) {
+		t.Fatalf("expected ErrUnsupportedOption, but got %v", err)
+	}
+}
+
+func TestBadRows(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		policy     yuccaTable.BadRowPolicy
+		compressed bool
+	}{
+		{"skip", yuccaTable.BadRowsSkip, false},
+		{"quarantine", yuccaTable.BadRowsQuarantine, false},
+		{"quarantine compressed", yuccaTable.BadRowsQuarantine, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			file := filepath.Join(dir, "test.csv")
+
+			// unsorted keys are not bad rows
+			lines := append(brokenCsv[:7:7], brokenCsv[8:]...)
+
+			if tt.compressed {
+				file += ".gz"
+				writeBGZF(t, file, lines...)
+			} else {
+				writeCsv(t, file, lines...)
+			}
+
+			original, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			opts := yuccaTable.BuildOptions{Header: true, BadRows: tt.policy}
+
+			table, err := yuccaTable.BuildTable(file, &recordLogger{}, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer table.Close()
+
+			if data, err := os.ReadFile(file); err != nil || string(data) != string(original) {
+				t.Fatalf("data file is modified: %v", err)
+			}
+
+			testGet(t, table, "a", []string{"1"})
+			testGet(t, table, "b", nil)
+			testGet(t, table, "c2", nil)
+			testGet(t, table, "e", nil)
+			testGet(t, table, "g", []string{"8"})
+
+			quarantined, err := os.ReadFile(file + ".rejected")
+
+			if tt.policy == yuccaTable.BadRowsSkip {
+				if !os.IsNotExist(err) {
+					t.Fatalf("expected no quarantine file, but got %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := "b,x\"y\nc2,3,extra\ne,\xff\n"; string(quarantined) != want {
+				t.Fatalf("expected quarantined %q, but got %q", want, quarantined)
+			}
+		})
+	}
+}
+
+func TestBadRowsLoadTable(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "test.csv")
+	content := "a,1\nb,2,extra\nc,\"unterminated\nd,4\n"
+
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := yuccaTable.BuildOptions{BadRows: yuccaTable.BadRowsSkip}
+
+	// prepare, then load from the index file of the prepared file
+	for _, wantLog := range []string{"preparing", "from index file"} {
+		logger := &recordLogger{}
+
+		table, err := yuccaTable.LoadTable(file, logger, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer table.Close()
+
+		if !logger.contains(wantLog) {
+			t.Fatalf("expected log %q, but got logs %q", wantLog, logger.messages)
+		}
+
+		testGet(t, table, "a", []string{"1"})
+		testGet(t, table, "b", nil)
+		testGet(t, table, "d", nil)
+	}
+
+	if data, err := os.ReadFile(file); err != nil || string(data) != content {
+		t.Fatalf("data file is modified: %q, %v", data, err)
+	}
+
+	// the data file without bad rows is loaded as it is
+	writeCsv(t, file, "a,1", "b,2")
+
+	table, err := yuccaTable.LoadTable(file, &recordLogger{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	testGet(t, table, "b", []string{"2"})
+
+	if _, err := os.Stat(yuccaTable.PreparedFile(file)); !os.IsNotExist(err) {
+		t.Fatalf("expected the stale prepared file to be removed, but got %v", err)
+	}
+}
+
+func writeBGZF(t *testing.T, file string, lines ...string) {
+	t.Helper()
+
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := bgzf.NewWriter(f)
+	for _, line := range lines {
+		if _, err := w.Write([]byte(line + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}